@@ -0,0 +1,333 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/alec-rabold/zipspy/pkg/reader"
+	"github.com/alec-rabold/zipspy/pkg/zipspy"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// catBufferSize bounds the size of each read from the archive,
+	// which keeps the number of range requests low for remote locations.
+	catBufferSize = 4 << 20 // 4 MiB
+	// tailChunkSize is the size of each backwards read when looking for the last lines of a stored or indexed file.
+	tailChunkSize = 64 << 10 // 64 KiB
+	// maxTailLineSize bounds the length of the lines kept in memory by "--tail" for files read in full.
+	maxTailLineSize = 1 << 20 // 1 MiB
+)
+
+func Cat() *cobra.Command {
+	var offset, length int64
+	var head, tail int
+	var index bool
+	var indexSpan int64
+	cmd := &cobra.Command{
+		Use:   "cat ENTRY [--offset N --length M | --head N | --tail N] [--index]",
+		Short: "Print the contents (or part of the contents) of a single file in a zip archive.",
+		Long: `Prints the contents of a single file within a zip archive, or only part of it.
+
+Use "--offset" and "--length" to print a range of (decompressed) bytes:
+
+	zipspy cat --location s3://my-bucket/archive.zip logs/app.log --offset 1048576 --length 4096
+
+Use "--head" or "--tail" to print the first or last lines of the file:
+
+	zipspy cat --location s3://my-bucket/archive.zip logs/app.log --tail 100
+
+For uncompressed (stored) files, only the requested bytes are downloaded.
+Compressed files are decompressed from the beginning, but reading stops as soon as the
+requested bytes have been printed (except for "--tail", which reads the whole file).
+Checksums are only verified when the whole file is read.
+
+Use "--index" for repeated random access to a large Deflate compressed file. The first time,
+the file is decompressed in full to record a checkpoint every "--index-span" MiB, and the index
+is saved in the cache directory. Afterwards, "--offset" and "--tail" only download the compressed
+data following the nearest checkpoint:
+
+	zipspy cat --location s3://my-bucket/archive.zip dump.sql --index --offset 5000000000 --length 4096
+`,
+		Args: cobra.ExactArgs(1),
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := cmd.Parent().PersistentPreRunE(cmd.Parent(), args); err != nil {
+				return fmt.Errorf("root pre-run failed: %v", err)
+			}
+			if err := validateCatCommand(offset, length, head, tail, indexSpan); err != nil {
+				cmd.Usage()
+				return fmt.Errorf("validation failed: %v", err)
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer cfg.closeReader()
+			zip, err := cfg.newClient()
+			if err != nil {
+				return fmt.Errorf("failed to create zipspy client: %v", err)
+			}
+			files := zip.GetFiles(args)
+			if len(files) == 0 {
+				return fmt.Errorf("file not found in archive (name: %s)", args[0])
+			}
+			file := files[0]
+			var sr *io.SectionReader
+			switch {
+			case isStored(file):
+				if sr, err = openStored(file); err != nil {
+					return fmt.Errorf("failed to read file (name: %s): %w", file.Name, err)
+				}
+			case index && head == 0:
+				if sr, err = openIndexed(zip, file, indexSpan<<20); err != nil {
+					return fmt.Errorf("failed to index file (name: %s): %w", file.Name, err)
+				}
+			}
+
+			w := bufio.NewWriter(os.Stdout)
+			defer w.Flush()
+			switch {
+			case head > 0:
+				err = catHead(w, file, head)
+			case tail > 0:
+				err = catTail(w, file, sr, tail)
+			default:
+				err = catRange(w, file, sr, offset, length)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read file (name: %s): %w", file.Name, err)
+			}
+			return nil
+		},
+	}
+	cmd.PersistentFlags().Int64Var(&offset, "offset", 0, "(optional) number of bytes to skip from the start of the file")
+	cmd.PersistentFlags().Int64Var(&length, "length", 0, "(optional) number of bytes to print, until the end of the file when 0")
+	cmd.PersistentFlags().IntVar(&head, "head", 0, "(optional) print only the first N lines")
+	cmd.PersistentFlags().IntVar(&tail, "tail", 0, "(optional) print only the last N lines")
+	cmd.PersistentFlags().BoolVar(&index, "index", false, "(optional) build (or load from the cache) a checkpoint index for random access to Deflate/Deflate64 compressed files")
+	cmd.PersistentFlags().Int64Var(&indexSpan, "index-span", reader.DefaultIndexSpan>>20, "(optional) distance between index checkpoints in MiB (uncompressed)")
+	return cmd
+}
+
+func validateCatCommand(offset, length int64, head, tail int, indexSpan int64) error {
+	if offset < 0 || length < 0 || head < 0 || tail < 0 {
+		return fmt.Errorf("--offset, --length, --head and --tail must not be negative")
+	}
+	if indexSpan < 1 {
+		return fmt.Errorf("--index-span must be at least 1")
+	}
+	modes := 0
+	if offset > 0 || length > 0 {
+		modes++
+	}
+	if head > 0 {
+		modes++
+	}
+	if tail > 0 {
+		modes++
+	}
+	if modes > 1 {
+		return fmt.Errorf("only one of --offset/--length, --head or --tail may be specified")
+	}
+	return nil
+}
+
+// isStored reports whether the file's data is stored in the archive as is,
+// so that any part of it can be read directly.
+func isStored(file *reader.File) bool {
+	return file.Method == reader.Store && file.Flags&0x1 == 0 // not encrypted
+}
+
+// openStored returns the raw data of a stored file.
+func openStored(file *reader.File) (*io.SectionReader, error) {
+	raw, err := file.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+	sr, ok := raw.(*io.SectionReader)
+	if !ok {
+		return nil, fmt.Errorf("raw reader does not support random access")
+	}
+	return sr, nil
+}
+
+// openIndexed returns a random access reader for a compressed file, using an index
+// loaded from the cache or built by decompressing the file once.
+func openIndexed(zip *zipspy.Client, file *reader.File, span int64) (*io.SectionReader, error) {
+	idx, err := zip.Index(file, span)
+	if err != nil {
+		return nil, err
+	}
+	r, err := file.OpenSeekable(idx)
+	if err != nil {
+		return nil, err
+	}
+	return io.NewSectionReader(r, 0, r.Size()), nil
+}
+
+// catRange writes length bytes of the file starting at offset (or the rest of the file if length is 0).
+// If sr is set, it is used to read the range directly.
+func catRange(w io.Writer, file *reader.File, sr *io.SectionReader, offset, length int64) error {
+	size := int64(file.UncompressedSize64)
+	if offset >= size {
+		return nil
+	}
+	if length == 0 || offset+length > size {
+		length = size - offset
+	}
+	buf := make([]byte, min64(length, catBufferSize))
+
+	// The whole file is read through Open, even if it is stored or indexed, so that its checksum is verified.
+	if sr != nil && (offset > 0 || length < size) {
+		_, err := io.CopyBuffer(w, io.NewSectionReader(sr, offset, length), buf)
+		return err
+	}
+
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if offset == 0 && length == size {
+		_, err = io.CopyBuffer(w, rc, buf)
+		return err
+	}
+	if _, err := io.CopyBuffer(io.Discard, io.LimitReader(rc, offset), buf); err != nil {
+		return err
+	}
+	_, err = io.CopyBuffer(w, io.LimitReader(rc, length), buf)
+	return err
+}
+
+// catHead writes the first n lines of the file, reading no further than necessary.
+func catHead(w io.Writer, file *reader.File, n int) error {
+	var r io.Reader
+	if isStored(file) {
+		sr, err := openStored(file)
+		if err != nil {
+			return err
+		}
+		r = sr
+	} else {
+		rc, err := file.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		r = rc
+	}
+	br := bufio.NewReader(r)
+	for i := 0; i < n; {
+		// Long lines are written as they are read rather than held in memory.
+		line, err := br.ReadSlice('\n')
+		if _, werr := w.Write(line); werr != nil {
+			return werr
+		}
+		switch err {
+		case nil:
+			i++
+		case bufio.ErrBufferFull:
+		case io.EOF:
+			return nil
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// catTail writes the last n lines of the file.
+// If sr is set the file is read backwards from the end, otherwise it must be read in full.
+func catTail(w io.Writer, file *reader.File, sr *io.SectionReader, n int) error {
+	if sr != nil {
+		start, err := tailOffset(sr, n)
+		if err != nil {
+			return err
+		}
+		_, err = io.CopyBuffer(w, io.NewSectionReader(sr, start, sr.Size()-start), make([]byte, min64(sr.Size()-start+1, catBufferSize)))
+		return err
+	}
+
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	// Keep the last n lines in a ring buffer, with nil for lines too long to be kept.
+	lines := make([][]byte, n)
+	count := 0
+	br := bufio.NewReader(rc)
+	for {
+		line, err := readLine(br, maxTailLineSize)
+		if err == errLineTooLong {
+			lines[count%n] = nil
+			count++
+			continue
+		}
+		if len(line) > 0 {
+			lines[count%n] = line
+			count++
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	first := 0
+	if count > n {
+		first = count - n
+	}
+	for i := first; i < count; i++ {
+		if lines[i%n] == nil {
+			return fmt.Errorf("line longer than %d bytes, use --index or --offset to read the end of the file", maxTailLineSize)
+		}
+	}
+	for i := first; i < count; i++ {
+		if _, err := w.Write(lines[i%n]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tailOffset returns the offset of the start of the last n lines of r,
+// reading backwards from the end in chunks.
+func tailOffset(r *io.SectionReader, n int) (int64, error) {
+	end := r.Size()
+	buf := make([]byte, tailChunkSize)
+	// A trailing newline terminates the last line rather than starting a new one.
+	newlines := 0
+	skipTrailing := true
+	for pos := end; pos > 0; {
+		chunk := buf[:min64(pos, tailChunkSize)]
+		pos -= int64(len(chunk))
+		if _, err := r.ReadAt(chunk, pos); err != nil && err != io.EOF {
+			return 0, err
+		}
+		for i := len(chunk) - 1; i >= 0; i-- {
+			if chunk[i] != '\n' {
+				skipTrailing = false
+				continue
+			}
+			if skipTrailing {
+				skipTrailing = false
+				continue
+			}
+			newlines++
+			if newlines == n {
+				return pos + int64(i) + 1, nil
+			}
+		}
+	}
+	return 0, nil
+}
+
+func min64(x, y int64) int64 {
+	if x < y {
+		return x
+	}
+	return y
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"strings"
+	"testing"
+
+	"github.com/alec-rabold/zipspy/pkg/reader"
+)
+
+// newTestArchive returns the files of an archive holding the given contents.
+// Names ending in ".txt" are stored, others deflated. The files named in badCRC are stored with a wrong checksum.
+func newTestArchive(t *testing.T, contents map[string]string, badCRC ...string) map[string]*reader.File {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range contents {
+		method := zip.Deflate
+		if strings.HasSuffix(name, ".txt") {
+			method = zip.Store
+		}
+		if contains(badCRC, name) {
+			// Stored data is written raw, so that the checksum isn't computed by the writer.
+			w, err := zw.CreateRaw(&zip.FileHeader{
+				Name:               name,
+				Method:             zip.Store,
+				CRC32:              crc32.ChecksumIEEE([]byte(data)) + 1,
+				CompressedSize64:   uint64(len(data)),
+				UncompressedSize64: uint64(len(data)),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Write([]byte(data))
+			continue
+		}
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(data))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := reader.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]*reader.File)
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	return files
+}
+
+func TestCatRangeVerifiesChecksum(t *testing.T) {
+	files := newTestArchive(t, map[string]string{"corrupt.txt": "0123456789"}, "corrupt.txt")
+	f := files["corrupt.txt"]
+	sr, err := openStored(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := catRange(&out, f, sr, 0, 0); !errors.Is(err, reader.ErrChecksum) {
+		t.Errorf("catRange of the whole file: error = %v, want %v", err, reader.ErrChecksum)
+	}
+	// Parts of a stored file are read directly, without a checksum to verify.
+	out.Reset()
+	if err := catRange(&out, f, sr, 2, 3); err != nil || out.String() != "234" {
+		t.Errorf("catRange(2, 3) = %q, %v, want %q", out.String(), err, "234")
+	}
+}
+
+func TestCatHeadAndTail(t *testing.T) {
+	long := strings.Repeat("x", maxTailLineSize+1) + "\n"
+	files := newTestArchive(t, map[string]string{
+		"short":     "one\ntwo\nthree\n",
+		"long-head": long + "tail\n",
+		"long-tail": "head\n" + long,
+	})
+	tests := []struct {
+		name    string
+		head    int
+		tail    int
+		want    string
+		wantErr bool
+	}{
+		{name: "short", head: 2, want: "one\ntwo\n"},
+		{name: "short", tail: 2, want: "two\nthree\n"},
+		{name: "short", tail: 5, want: "one\ntwo\nthree\n"},
+		{name: "long-head", head: 1, want: long},
+		// Long lines are skipped unless they are among the last lines.
+		{name: "long-head", tail: 1, want: "tail\n"},
+		{name: "long-tail", tail: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		var err error
+		if tt.head > 0 {
+			err = catHead(&out, files[tt.name], tt.head)
+		} else {
+			err = catTail(&out, files[tt.name], nil, tt.tail)
+		}
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s (head %d, tail %d): no error", tt.name, tt.head, tt.tail)
+			}
+			continue
+		}
+		if err != nil || out.String() != tt.want {
+			t.Errorf("%s (head %d, tail %d) = %.20q, %v, want %.20q", tt.name, tt.head, tt.tail, out.String(), err, tt.want)
+		}
+	}
+}
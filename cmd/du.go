@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/alec-rabold/zipspy/pkg/reader"
+	"github.com/spf13/cobra"
+)
+
+func Du() *cobra.Command {
+	var depth int
+	var human bool
+	cmd := &cobra.Command{
+		Use:   "du [--depth N] [--human] [PATH]",
+		Short: "Summarize the sizes of directories in a zip archive.",
+		Long: `Prints the total uncompressed and compressed sizes of the files within each directory
+of a zip archive, like "du", starting at the root of the archive or the given directory:
+
+	zipspy du --location file://archive.zip --depth 1
+	zipspy du --location file://archive.zip --human path/to
+
+Each line contains the uncompressed size, the compressed size and the directory.
+`,
+		Args: cobra.MaximumNArgs(1),
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := cmd.Parent().PersistentPreRunE(cmd.Parent(), args); err != nil {
+				return fmt.Errorf("root pre-run failed: %v", err)
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer cfg.closeReader()
+			root, err := fsPathArg(args)
+			if err != nil {
+				return err
+			}
+			zip, err := cfg.newClient()
+			if err != nil {
+				return fmt.Errorf("failed to create zipspy client: %v", err)
+			}
+
+			w := bufio.NewWriter(os.Stdout)
+			defer w.Flush()
+			d := &diskUsage{fsys: zip.FS(), w: w, maxDepth: depth, human: human}
+			_, _, err = d.walk(root, 0)
+			return err
+		},
+	}
+	cmd.PersistentFlags().IntVar(&depth, "depth", -1, "(optional) only print totals for directories this many levels below PATH, unlimited when negative")
+	cmd.PersistentFlags().BoolVar(&human, "human", false, "(optional) print sizes in human readable units (e.g. 1.5M)")
+	return cmd
+}
+
+type diskUsage struct {
+	fsys     fs.FS
+	w        io.Writer
+	maxDepth int
+	human    bool
+}
+
+// walk returns the total uncompressed and compressed sizes of the files beneath dir,
+// printing the totals of each directory after those of its subdirectories.
+func (d *diskUsage) walk(dir string, depth int) (uncompressed, compressed uint64, err error) {
+	entries, err := fs.ReadDir(d.fsys, dir)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read directory (path: %s): %w", dir, err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			u, c, err := d.walk(joinFSPath(dir, entry.Name()), depth+1)
+			if err != nil {
+				return 0, 0, err
+			}
+			uncompressed += u
+			compressed += c
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return 0, 0, err
+		}
+		if fh, ok := info.Sys().(*reader.FileHeader); ok {
+			uncompressed += fh.UncompressedSize64
+			compressed += fh.CompressedSize64
+		}
+	}
+	if d.maxDepth < 0 || depth <= d.maxDepth {
+		fmt.Fprintf(d.w, "%s\t%s\t%s\n", d.size(uncompressed), d.size(compressed), dir)
+	}
+	return uncompressed, compressed, nil
+}
+
+func (d *diskUsage) size(n uint64) string {
+	if !d.human {
+		return fmt.Sprint(n)
+	}
+	return humanSize(n)
+}
+
+// humanSize formats a number of bytes using binary units (e.g. 1.5M).
+func humanSize(n uint64) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+	v := float64(n)
+	i := -1
+	for v >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f%c", v, units[i])
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDu(t *testing.T) {
+	zr := newTestReader(t, treeContents)
+	// totals returns the line du prints for dir, summing the sizes of the files beneath it.
+	totals := func(dir string) string {
+		var uncompressed, compressed uint64
+		for _, f := range zr.File {
+			if dir == "." || strings.HasPrefix(f.Name, dir+"/") {
+				uncompressed += f.UncompressedSize64
+				compressed += f.CompressedSize64
+			}
+		}
+		return fmt.Sprintf("%d\t%d\t%s\n", uncompressed, compressed, dir)
+	}
+
+	tests := []struct {
+		name  string
+		root  string
+		depth int
+		want  []string // directories, subdirectories before their parents
+	}{
+		{name: "whole archive", root: ".", depth: -1, want: []string{"docs/api", "docs", "src/util", "src", "."}},
+		{name: "depth", root: ".", depth: 1, want: []string{"docs", "src", "."}},
+		{name: "path", root: "src", depth: -1, want: []string{"src/util", "src"}},
+		{name: "path and depth", root: "src", depth: 0, want: []string{"src"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			d := &diskUsage{fsys: zr, w: &out, maxDepth: tt.depth}
+			if _, _, err := d.walk(tt.root, 0); err != nil {
+				t.Fatal(err)
+			}
+			var want strings.Builder
+			for _, dir := range tt.want {
+				want.WriteString(totals(dir))
+			}
+			if out.String() != want.String() {
+				t.Errorf("du =\n%s\nwant\n%s", out.String(), want.String())
+			}
+		})
+	}
+}
+
+func TestHumanSize(t *testing.T) {
+	tests := []struct {
+		n    uint64
+		want string
+	}{
+		{0, "0B"},
+		{1023, "1023B"},
+		{1024, "1.0K"},
+		{1536, "1.5K"},
+		{5 << 20, "5.0M"},
+		{3 << 40, "3.0T"},
+	}
+	for _, tt := range tests {
+		if got := humanSize(tt.n); got != tt.want {
+			t.Errorf("humanSize(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
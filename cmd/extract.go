@@ -2,10 +2,14 @@ package cmd
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"regexp"
 	"strings"
 
+	"github.com/alec-rabold/zipspy/pkg/extract"
 	"github.com/alec-rabold/zipspy/pkg/reader"
 	"github.com/alec-rabold/zipspy/pkg/zipspy"
 	log "github.com/sirupsen/logrus"
@@ -13,7 +17,9 @@ import (
 )
 
 func Extract() *cobra.Command {
-	var all bool
+	var all, failFast, unsafePaths bool
+	var workers int
+	var destDir string
 	var inFiles, outFiles []string
 	cmd := &cobra.Command{
 		Use:   "extract -file f1.txt [--out out.txt] [--separator \"\\n---\\n\"]",
@@ -38,6 +44,29 @@ You may optionally include the "--out/-o" flag to write the contents to a file i
 If you specify more than one output, each file will be writen to the corresponding desination:
 
 	zipspy extract --location file://archive.zip -f file1.txt -o dest1.txt -f file2.txt -o dest2.txt -f file3.txt -o dest3.txt
+
+Files may also be selected by name with a regular expression ("--match") or glob patterns
+("--glob", where "**" matches any number of directories), and skipped with "--exclude":
+
+	zipspy extract --location file://archive.zip --match '\.json$'
+	zipspy extract --location file://archive.zip --glob 'logs/**/*.log' --exclude 'logs/debug/**'
+
+Use the "--dest-dir" flag to recreate the archive's directory structure, including
+permissions, modification times, directories and symlinks, beneath a directory:
+
+	zipspy extract --location file://archive.zip --all --dest-dir ./archive
+
+Entries with absolute paths, ".." traversal or symlinks pointing outside of the
+destination are rejected unless the "--unsafe-paths" flag is set for trusted archives.
+
+Use the "--workers" flag to download and decompress multiple files concurrently.
+Output is still written in the order the files were found. A file that fails to extract
+does not stop the others unless the "--fail-fast" flag is set. When files are written to
+stdout or to a single "--out" file, each one is first decompressed into a temporary file
+(in $TMPDIR) until its turn comes, which may need up to as much space as the extracted files.
+With "--dest-dir" or one "--out" per file, files are written directly to their destination:
+
+	zipspy extract --location s3://my-bucket/archive.zip --all --workers 8 --separator "\n---\n"
 `,
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			if err := cmd.Parent().PersistentPreRunE(cmd.Parent(), args); err != nil {
@@ -50,12 +79,16 @@ If you specify more than one output, each file will be writen to the correspondi
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			zip, err := zipspy.NewClient(cfg.zipReader)
+			defer cfg.closeReader()
+			zip, err := cfg.newClient()
 			if err != nil {
 				return fmt.Errorf("failed to create zipspy client: %v", err)
 			}
 
-			files := getFiles(cmd, zip)
+			files, err := getFiles(cmd, zip)
+			if err != nil {
+				return err
+			}
 			if len(inFiles) != 0 && len(inFiles) != len(files) {
 				if len(outFiles) > 1 {
 					return fmt.Errorf("number of input files must match number of found files in order to write to multiple files (input: %d) (found: %d)", len(inFiles), len(files))
@@ -65,36 +98,98 @@ If you specify more than one output, each file will be writen to the correspondi
 
 			outFile := os.Stdout
 			if len(outFiles) == 1 {
-				outFile, err = os.OpenFile(outFiles[0], os.O_CREATE|os.O_WRONLY, 0644)
+				outFile, err = os.OpenFile(outFiles[0], os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 				if err != nil {
 					return fmt.Errorf("failed to open file (name: %s): %w", outFiles[0], err)
 				}
 				defer outFile.Close()
 			}
-			// TODO: parallelize with variable number of workers here
-			for idx, file := range files {
+
+			// Each result holds an entry's contents until it can be written in order.
+			// With a single worker the entry is streamed directly, otherwise it is spooled to a temporary file.
+			results := make([]io.ReadCloser, len(files))
+			defer func() {
+				for _, rc := range results {
+					if rc != nil {
+						rc.Close()
+					}
+				}
+			}()
+			var extractor *extract.Extractor
+			if destDir != "" {
+				var opts []extract.Option
+				if unsafePaths {
+					opts = append(opts, extract.WithUnsafePaths())
+				}
+				extractor = extract.NewExtractor(destDir, opts...)
+			}
+			work := func(idx int) error {
+				file := files[idx]
+				if extractor != nil {
+					return extractor.Extract(file)
+				}
 				// Kind of hacky, but skip if directory
 				if strings.HasSuffix(file.Name, "/") {
-					continue
+					return nil
 				}
 				rc, err := file.Open()
 				if err != nil {
 					return fmt.Errorf("failed to open file (name: %s): %w", file.Name, err)
 				}
-				defer rc.Close()
-
 				if len(outFiles) > 1 {
-					outFile, err = os.OpenFile(outFiles[idx], os.O_CREATE|os.O_WRONLY, 0644)
+					defer rc.Close()
+					out, err := os.OpenFile(outFiles[idx], os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 					if err != nil {
-						return fmt.Errorf("failed to open file (name: %s): %w", outFiles[0], err)
+						return fmt.Errorf("failed to open file (name: %s): %w", outFiles[idx], err)
 					}
-					defer outFile.Close()
+					defer out.Close()
+					if err := writeToFile(bufio.NewReader(rc), bufio.NewWriter(out), buildSeparator(cmd)); err != nil {
+						return fmt.Errorf("failed writing contents of %s to file: %w", file.Name, err)
+					}
+					return nil
+				}
+				if workers <= 1 {
+					results[idx] = rc
+					return nil
 				}
+				defer rc.Close()
+				spool, err := spoolToTempFile(rc)
+				if err != nil {
+					return fmt.Errorf("failed to extract file (name: %s): %w", file.Name, err)
+				}
+				results[idx] = spool
+				return nil
+			}
+			done := func(idx int) error {
+				rc := results[idx]
+				if rc == nil {
+					return nil
+				}
+				defer func() {
+					rc.Close()
+					results[idx] = nil
+				}()
 				if err := writeToFile(bufio.NewReader(rc), bufio.NewWriter(outFile), buildSeparator(cmd)); err != nil {
-					return fmt.Errorf("failed writing contents to file: %w", err)
+					return fmt.Errorf("failed writing contents of %s to file: %w", files[idx].Name, err)
 				}
+				return nil
 			}
 
+			errs := forEachOrdered(len(files), workers, failFast, work, done)
+			if extractor != nil {
+				errs = append(errs, extractor.Finish()...)
+			}
+			var unsafePathErr *extract.UnsafePathError
+			for _, err := range errs {
+				log.Error(err)
+				errors.As(err, &unsafePathErr)
+			}
+			if unsafePathErr != nil {
+				log.Warn("refused to write outside of --dest-dir, use --unsafe-paths only if you trust this archive")
+			}
+			if len(errs) > 0 {
+				return fmt.Errorf("failed to extract %d file(s)", len(errs))
+			}
 			return nil
 		},
 	}
@@ -102,27 +197,71 @@ If you specify more than one output, each file will be writen to the correspondi
 	cmd.PersistentFlags().StringSliceVarP(&outFiles, "out", "o", []string{}, "(optional) name(s) of the file(s) to write output to")
 	cmd.PersistentFlags().String("separator", "", "(optional) separator when combining the output of multiple files")
 	cmd.PersistentFlags().BoolVar(&all, "all", false, "(optional) whether to extract all files in the zip archive")
+	cmd.PersistentFlags().String("match", "", "(optional) only extract files whose names match this regular expression")
+	cmd.PersistentFlags().StringSlice("glob", []string{}, "(optional) only extract files matching these glob patterns (e.g. \"logs/**/*.log\")")
+	cmd.PersistentFlags().StringSlice("exclude", []string{}, "(optional) skip files matching these glob patterns")
 	cmd.PersistentFlags().Bool("no-newlines", false, "(optional) omit the newlines appended to files")
+	cmd.PersistentFlags().StringVar(&destDir, "dest-dir", "", "(optional) directory to extract files into, recreating the archive's directory structure")
+	cmd.PersistentFlags().BoolVar(&unsafePaths, "unsafe-paths", false, "(optional) allow --dest-dir entries with absolute paths, \"..\" traversal or symlinks leaving the destination (trusted archives only)")
+	cmd.PersistentFlags().IntVar(&workers, "workers", 1, "(optional) number of files to download and decompress concurrently")
+	cmd.PersistentFlags().BoolVar(&failFast, "fail-fast", false, "(optional) stop extracting after the first file that fails")
 	return cmd
 }
 
 func validateExtractCommand(cmd *cobra.Command) error {
+	all, _ := cmd.Flags().GetBool("all")
+	match, _ := cmd.Flags().GetString("match")
+	globs, _ := cmd.Flags().GetStringSlice("glob")
+	if inFiles, _ := cmd.Flags().GetStringSlice("file"); len(inFiles) == 0 && !all && match == "" && len(globs) == 0 {
+		return fmt.Errorf("at least one file must be specified, or use the --all, --match or --glob flags")
+	}
+	if workers, _ := cmd.Flags().GetInt("workers"); workers < 1 {
+		return fmt.Errorf("--workers must be at least 1")
+	}
 	files, err := cmd.Flags().GetStringSlice("file")
 	if err != nil {
 		return fmt.Errorf("at least one file must be specified, or use the --all flag: %v", err)
 	}
 	outfiles, _ := cmd.Flags().GetStringSlice("out")
+	if destDir, _ := cmd.Flags().GetString("dest-dir"); destDir != "" && len(outfiles) > 0 {
+		return fmt.Errorf("--dest-dir cannot be combined with --out")
+	}
 	if len(outfiles) > 1 && (len(outfiles) != len(files)) {
 		return fmt.Errorf("one output file must be specified for each search term, or you may use a single output file")
 	}
 	return nil
 }
 
-func getFiles(cmd *cobra.Command, zip *zipspy.Client) []*reader.File {
+func getFiles(cmd *cobra.Command, zip *zipspy.Client) ([]*reader.File, error) {
 	all, _ := cmd.Flags().GetBool("all")
 	inFiles, _ := cmd.Flags().GetStringSlice("file")
-	if all {
-		return zip.AllFiles()
+	match, _ := cmd.Flags().GetString("match")
+	globs, _ := cmd.Flags().GetStringSlice("glob")
+	excludes, _ := cmd.Flags().GetStringSlice("exclude")
+
+	files := zip.AllFiles()
+	if !all && len(inFiles) > 0 {
+		files = zip.GetFiles(inFiles)
+	}
+	if match != "" {
+		re, err := regexp.Compile(match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %w", match, err)
+		}
+		files = filterRegexp(files, re)
+	}
+	if len(globs) == 0 && len(excludes) == 0 {
+		return files, nil
+	}
+	return zipspy.FilterFiles(files, globs, excludes)
+}
+
+func filterRegexp(files []*reader.File, re *regexp.Regexp) []*reader.File {
+	var matches []*reader.File
+	for _, file := range files {
+		if re.MatchString(file.Name) {
+			matches = append(matches, file)
+		}
 	}
-	return zip.GetFiles(inFiles)
+	return matches
 }
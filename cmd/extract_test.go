@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// writeTestArchive writes an archive of the given files, sorted by name, to a temporary directory and returns its location.
+func writeTestArchive(t *testing.T, contents map[string]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "archive.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	names := make([]string, 0, len(contents))
+	for name := range contents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(contents[name]))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return "file://" + path
+}
+
+// runCommand executes the root command with the given arguments and returns what it printed to the standard output.
+func runCommand(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	out, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	stdout := os.Stdout
+	os.Stdout = out
+	defer func() { os.Stdout = stdout }()
+	root := Root()
+	root.SetArgs(args)
+	// Errors are returned, rather than printed with the usage.
+	root.SetOut(io.Discard)
+	root.SetErr(io.Discard)
+	err = root.Execute()
+	b, rerr := os.ReadFile(out.Name())
+	if rerr != nil {
+		t.Fatal(rerr)
+	}
+	return string(b), err
+}
+
+func TestExtractTruncatesOutput(t *testing.T) {
+	location := writeTestArchive(t, map[string]string{"a.txt": "new a", "b.txt": "new b"})
+	dir := t.TempDir()
+	single := filepath.Join(dir, "single.txt")
+	outA, outB := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")
+	for _, name := range []string{single, outA, outB} {
+		if err := os.WriteFile(name, []byte("previous contents, longer than the new ones"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		args []string
+		want map[string]string
+	}{
+		{
+			args: []string{"-f", "a.txt", "-o", single, "--no-newlines"},
+			want: map[string]string{single: "new a"},
+		},
+		{
+			args: []string{"-f", "a.txt", "-o", outA, "-f", "b.txt", "-o", outB, "--no-newlines", "--workers", "2"},
+			want: map[string]string{outA: "new a", outB: "new b"},
+		},
+	}
+	for _, tt := range tests {
+		root := Root()
+		root.SetArgs(append([]string{"extract", "--location", location, "--no-directory-cache"}, tt.args...))
+		if err := root.Execute(); err != nil {
+			t.Fatalf("extract %v: %v", tt.args, err)
+		}
+		for name, want := range tt.want {
+			b, err := os.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != want {
+				t.Errorf("extract %v: %s = %q, want %q", tt.args, filepath.Base(name), b, want)
+			}
+		}
+	}
+}
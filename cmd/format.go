@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alec-rabold/zipspy/pkg/reader"
+)
+
+// Output formats supported by the list command.
+const (
+	formatName  = "name"
+	formatLong  = "long"
+	formatJSON  = "json"
+	formatJSONL = "jsonl"
+	formatCSV   = "csv"
+)
+
+var listFormats = []string{formatName, formatLong, formatJSON, formatJSONL, formatCSV}
+
+// listEntry is the schema of a file printed by the list command in the json, jsonl and csv formats.
+// Fields may be added in the future, but existing fields will not be renamed or removed.
+type listEntry struct {
+	Name             string    `json:"name"`
+	Directory        bool      `json:"directory"`
+	UncompressedSize uint64    `json:"uncompressed_size"`
+	CompressedSize   uint64    `json:"compressed_size"`
+	CompressionRatio float64   `json:"compression_ratio"` // compressed size / uncompressed size, 0 for empty files
+	Method           string    `json:"method"`
+	MethodID         uint16    `json:"method_id"`
+	CRC32            string    `json:"crc32"` // 8 lowercase hex digits
+	Modified         time.Time `json:"modified"`
+	Mode             string    `json:"mode"` // as formatted by fs.FileMode, e.g. "-rw-r--r--"
+	Comment          string    `json:"comment"`
+	Encryption       string    `json:"encryption"` // "zipcrypto", "aes-128", "aes-192", "aes-256" or "" if not encrypted
+}
+
+var listCSVHeader = []string{
+	"name", "directory", "uncompressed_size", "compressed_size", "compression_ratio",
+	"method", "method_id", "crc32", "modified", "mode", "comment", "encryption",
+}
+
+func newListEntry(f *reader.File) listEntry {
+	var ratio float64
+	if f.UncompressedSize64 > 0 {
+		ratio = float64(f.CompressedSize64) / float64(f.UncompressedSize64)
+	}
+	return listEntry{
+		Name:             f.Name,
+		Directory:        strings.HasSuffix(f.Name, "/"),
+		UncompressedSize: f.UncompressedSize64,
+		CompressedSize:   f.CompressedSize64,
+		CompressionRatio: ratio,
+		Method:           methodName(f.CompressionMethod()),
+		MethodID:         f.CompressionMethod(),
+		CRC32:            fmt.Sprintf("%08x", f.CRC32),
+		Modified:         f.Modified,
+		Mode:             f.Mode().String(),
+		Comment:          f.Comment,
+		Encryption:       f.Encryption(),
+	}
+}
+
+// methodName returns a human readable name for a compression method.
+func methodName(method uint16) string {
+	switch method {
+	case reader.Store:
+		return "store"
+	case reader.Deflate:
+		return "deflate"
+	case reader.Deflate64:
+		return "deflate64"
+	case reader.Bzip2:
+		return "bzip2"
+	case reader.LZMA:
+		return "lzma"
+	case reader.Zstd:
+		return "zstd"
+	case reader.XZ:
+		return "xz"
+	case reader.AES:
+		return "aes"
+	default:
+		return fmt.Sprintf("method-%d", method)
+	}
+}
+
+// writeListing writes files to w in the given format.
+func writeListing(w io.Writer, format string, files []*reader.File) error {
+	switch format {
+	case formatLong:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "Mode\tSize\tCompressed\tRatio\tMethod\tCRC-32\tModified\t Name")
+		for _, f := range files {
+			e := newListEntry(f)
+			method := e.Method
+			if e.Encryption != "" {
+				method += " (" + e.Encryption + ")"
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f%%\t%s\t%s\t%s\t %s\n",
+				e.Mode, e.UncompressedSize, e.CompressedSize, e.CompressionRatio*100, method, e.CRC32,
+				e.Modified.Format("2006-01-02 15:04:05"), e.Name)
+		}
+		return tw.Flush()
+	case formatJSON:
+		entries := make([]listEntry, 0, len(files))
+		for _, f := range files {
+			entries = append(entries, newListEntry(f))
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	case formatJSONL:
+		enc := json.NewEncoder(w)
+		for _, f := range files {
+			if err := enc.Encode(newListEntry(f)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(listCSVHeader); err != nil {
+			return err
+		}
+		for _, f := range files {
+			e := newListEntry(f)
+			err := cw.Write([]string{
+				e.Name,
+				strconv.FormatBool(e.Directory),
+				strconv.FormatUint(e.UncompressedSize, 10),
+				strconv.FormatUint(e.CompressedSize, 10),
+				strconv.FormatFloat(e.CompressionRatio, 'f', -1, 64),
+				e.Method,
+				strconv.FormatUint(uint64(e.MethodID), 10),
+				e.CRC32,
+				e.Modified.Format(time.RFC3339),
+				e.Mode,
+				e.Comment,
+				e.Encryption,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("unsupported format %q (supported: %s)", format, strings.Join(listFormats, ", "))
+	}
+}
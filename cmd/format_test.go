@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/alec-rabold/zipspy/pkg/reader"
+)
+
+func TestListEntryEncryption(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	headers := []*zip.FileHeader{
+		{Name: "plain", Method: zip.Deflate},
+		{Name: "zipcrypto", Method: zip.Deflate, Flags: 0x1},
+		// WinZip AES extra field: AE-2, vendor "AE", AES-256, Deflate.
+		{Name: "aes", Method: reader.AES, Flags: 0x1, Extra: []byte{0x01, 0x99, 7, 0, 2, 0, 'A', 'E', 3, 8, 0}},
+	}
+	for _, fh := range headers {
+		if _, err := zw.CreateRaw(fh); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := reader.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		methodID   uint16
+		encryption string
+	}{
+		{"plain", "deflate", reader.Deflate, ""},
+		{"zipcrypto", "deflate", reader.Deflate, "zipcrypto"},
+		{"aes", "deflate", reader.Deflate, "aes-256"},
+	}
+	for i, tt := range tests {
+		e := newListEntry(zr.File[i])
+		if e.Name != tt.name || e.Method != tt.method || e.MethodID != tt.methodID || e.Encryption != tt.encryption {
+			t.Errorf("newListEntry(%s) = %s, %s (%d), %q, want %s (%d), %q",
+				tt.name, e.Name, e.Method, e.MethodID, e.Encryption, tt.method, tt.methodID, tt.encryption)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := writeListing(&out, formatJSONL, zr.File[2:]); err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &fields); err != nil {
+		t.Fatal(err)
+	}
+	if fields["method"] != "deflate" || fields["encryption"] != "aes-256" {
+		t.Errorf("jsonl listing = %s, want method deflate and encryption aes-256", out.Bytes())
+	}
+}
@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/alec-rabold/zipspy/pkg/reader"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func Grep() *cobra.Command {
+	var includes, excludes []string
+	var filesWithMatches, count, ignoreCase, text, failFast bool
+	var maxBytes int64
+	var workers int
+	cmd := &cobra.Command{
+		Use:   "grep PATTERN [--include \"**/*.log\"] [-l] [-c] [--max-bytes N]",
+		Short: "Search for a regular expression inside files in a zip archive.",
+		Long: `Streams files from the zip archive and prints each line matching a regular expression
+as "file:line number:line", like "grep -rn". Only the files being searched are downloaded.
+
+	zipspy grep --location s3://my-bucket/archive.zip 'connection refused'
+
+Use "--include" and "--exclude" glob patterns to choose which files are searched:
+
+	zipspy grep --location s3://my-bucket/archive.zip --include 'logs/**/*.log' 'ERROR'
+
+Use "-l" to only print the names of matching files, or "-c" to print the number of matching lines per file.
+The "--max-bytes" flag limits how much of each (decompressed) file is searched.
+
+Like "grep -I", binary files (containing a NUL byte near their start) are skipped unless "--text" is set.
+Lines longer than 1 MiB are skipped rather than held in memory.
+With more than one worker, the matching lines of each file are spooled to a temporary file (in $TMPDIR)
+when they don't fit in 64 KiB of memory, until they can be printed in order.
+`,
+		Args: cobra.ExactArgs(1),
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := cmd.Parent().PersistentPreRunE(cmd.Parent(), args); err != nil {
+				return fmt.Errorf("root pre-run failed: %v", err)
+			}
+			if workers < 1 {
+				cmd.Usage()
+				return fmt.Errorf("validation failed: --workers must be at least 1")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer cfg.closeReader()
+			pattern := args[0]
+			if ignoreCase {
+				pattern = "(?i)" + pattern
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("invalid regular expression %q: %w", args[0], err)
+			}
+
+			zip, err := cfg.newClient()
+			if err != nil {
+				return fmt.Errorf("failed to create zipspy client: %v", err)
+			}
+			files, err := zip.GlobFiles(includes, excludes)
+			if err != nil {
+				return err
+			}
+
+			// Each result holds an entry's count and matching lines until they can be printed in order.
+			// With a single worker matching lines are printed directly, otherwise they are spooled.
+			stream := workers <= 1 && !filesWithMatches && !count
+			results := make([]*grepResult, len(files))
+			defer func() {
+				for _, result := range results {
+					if result != nil && result.matches != nil {
+						result.matches.Close()
+					}
+				}
+			}()
+			out := bufio.NewWriter(os.Stdout)
+			defer out.Flush()
+			search := func(file *reader.File, w io.Writer) (int, error) {
+				n, err := grepFile(file, re, maxBytes, text, filesWithMatches, w)
+				if err != nil {
+					return n, fmt.Errorf("failed to search file (name: %s): %w", file.Name, err)
+				}
+				return n, nil
+			}
+			work := func(idx int) error {
+				file := files[idx]
+				if stream || strings.HasSuffix(file.Name, "/") {
+					return nil
+				}
+				if filesWithMatches || count {
+					n, err := search(file, nil)
+					if err != nil {
+						return err
+					}
+					results[idx] = &grepResult{count: n}
+					return nil
+				}
+				spool := &spoolWriter{}
+				n, err := search(file, spool)
+				if err != nil {
+					spool.Close()
+					return err
+				}
+				matches, err := spool.Reader()
+				if err != nil {
+					spool.Close()
+					return fmt.Errorf("failed to search file (name: %s): %w", file.Name, err)
+				}
+				results[idx] = &grepResult{count: n, matches: matches}
+				return nil
+			}
+			done := func(idx int) error {
+				if stream {
+					if strings.HasSuffix(files[idx].Name, "/") {
+						return nil
+					}
+					_, err := search(files[idx], out)
+					return err
+				}
+				result := results[idx]
+				if result == nil {
+					return nil
+				}
+				results[idx] = nil
+				name := files[idx].Name
+				var err error
+				switch {
+				case filesWithMatches:
+					if result.count > 0 {
+						_, err = fmt.Fprintln(out, name)
+					}
+				case count:
+					_, err = fmt.Fprintf(out, "%s:%d\n", name, result.count)
+				default:
+					_, err = io.Copy(out, result.matches)
+					result.matches.Close()
+				}
+				return err
+			}
+
+			errs := forEachOrdered(len(files), workers, failFast, work, done)
+			for _, err := range errs {
+				log.Error(err)
+			}
+			if len(errs) > 0 {
+				return fmt.Errorf("failed to search %d file(s)", len(errs))
+			}
+			return nil
+		},
+	}
+	cmd.PersistentFlags().StringSliceVar(&includes, "include", []string{}, "(optional) only search files matching these glob patterns (e.g. \"logs/**/*.log\")")
+	cmd.PersistentFlags().StringSliceVar(&excludes, "exclude", []string{}, "(optional) skip files matching these glob patterns")
+	cmd.PersistentFlags().BoolVarP(&filesWithMatches, "files-with-matches", "l", false, "(optional) only print the names of files containing matches")
+	cmd.PersistentFlags().BoolVarP(&count, "count", "c", false, "(optional) only print the number of matching lines per file")
+	cmd.PersistentFlags().BoolVarP(&ignoreCase, "ignore-case", "i", false, "(optional) match case-insensitively")
+	cmd.PersistentFlags().BoolVarP(&text, "text", "a", false, "(optional) also search binary files")
+	cmd.PersistentFlags().Int64Var(&maxBytes, "max-bytes", 0, "(optional) maximum number of (decompressed) bytes to search per file, unlimited when 0")
+	cmd.PersistentFlags().IntVar(&workers, "workers", 4, "(optional) number of files to search concurrently")
+	cmd.PersistentFlags().BoolVar(&failFast, "fail-fast", false, "(optional) stop searching after the first file that fails")
+	return cmd
+}
+
+type grepResult struct {
+	count   int
+	matches io.ReadCloser // matching lines as printed, nil if they aren't printed
+}
+
+const (
+	// maxGrepLineSize bounds the length of the lines searched, longer lines are skipped.
+	maxGrepLineSize = 1 << 20 // 1 MiB
+	// binarySniffSize is the number of bytes at the start of a file checked for NUL bytes to detect binary files.
+	binarySniffSize = 8000
+)
+
+// grepFile searches the contents of a file line by line and returns the number of matching lines.
+// Binary files are skipped unless text is set.
+// If firstOnly is set, it stops reading the file at the first match.
+// Matching lines are written to w as "file:line number:line" if w isn't nil, otherwise they are just counted.
+func grepFile(file *reader.File, re *regexp.Regexp, maxBytes int64, text, firstOnly bool, w io.Writer) (int, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	var r io.Reader = rc
+	if maxBytes > 0 {
+		r = io.LimitReader(rc, maxBytes)
+	}
+
+	count := 0
+	br := bufio.NewReaderSize(r, binarySniffSize)
+	if !text {
+		head, err := br.Peek(binarySniffSize)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if bytes.IndexByte(head, 0) >= 0 {
+			log.Debugf("skipped binary file (name: %s)", file.Name)
+			return 0, nil
+		}
+	}
+	skipped := 0
+	for lineNum := 1; ; lineNum++ {
+		line, err := readLine(br, maxGrepLineSize)
+		if err == errLineTooLong {
+			skipped++
+			continue
+		}
+		if len(line) > 0 {
+			line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+			if re.Match(line) {
+				count++
+				if w != nil {
+					if _, err := fmt.Fprintf(w, "%s:%d:%s\n", file.Name, lineNum, line); err != nil {
+						return count, err
+					}
+				}
+				if firstOnly {
+					break
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return count, err
+		}
+	}
+	if skipped > 0 {
+		log.Warnf("skipped %d line(s) longer than %d bytes (name: %s)", skipped, maxGrepLineSize, file.Name)
+	}
+	return count, nil
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGrepFile(t *testing.T) {
+	long := strings.Repeat("match ", maxGrepLineSize/6+1) + "\n"
+	files := newTestArchive(t, map[string]string{
+		"lines.txt":  "one match\ntwo\nthree match\r\n",
+		"long.log":   "first match\n" + long + "last match\n",
+		"binary.bin": "match\x00\x01\x02",
+	})
+	re := regexp.MustCompile("match")
+	tests := []struct {
+		name  string
+		text  bool
+		count int
+		want  string
+	}{
+		{name: "lines.txt", count: 2, want: "lines.txt:1:one match\nlines.txt:3:three match\n"},
+		// The long line is skipped, but still counted for the following line numbers.
+		{name: "long.log", count: 2, want: "long.log:1:first match\nlong.log:3:last match\n"},
+		{name: "binary.bin"},
+		{name: "binary.bin", text: true, count: 1, want: "binary.bin:1:match\x00\x01\x02\n"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		count, err := grepFile(files[tt.name], re, 0, tt.text, false, &out)
+		if err != nil || count != tt.count || out.String() != tt.want {
+			t.Errorf("%s (text %v) = %d, %q, %v, want %d, %q", tt.name, tt.text, count, out.String(), err, tt.count, tt.want)
+		}
+	}
+}
+
+func TestGrepWorkers(t *testing.T) {
+	// big.log has more matching lines than are held in memory, so they are spooled to a temporary file.
+	var big, want strings.Builder
+	for i := 1; big.Len() <= maxSpoolMemory; i++ {
+		fmt.Fprintf(&big, "match %d\n", i)
+		fmt.Fprintf(&want, "big.log:%d:match %d\n", i, i)
+	}
+	contents := map[string]string{"big.log": big.String(), "dir/": ""}
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("small-%02d.log", i)
+		contents[name] = fmt.Sprintf("no\nmatch %d\n", i)
+		fmt.Fprintf(&want, "%s:2:match %d\n", name, i)
+	}
+	location := writeTestArchive(t, contents)
+	for _, workers := range []string{"1", "4"} {
+		out, err := runCommand(t, "grep", "--location", location, "--no-directory-cache", "--workers", workers, "match")
+		if err != nil {
+			t.Fatalf("grep --workers %s: %v", workers, err)
+		}
+		if out != want.String() {
+			t.Errorf("grep --workers %s = %.100q, want %.100q", workers, out, want.String())
+		}
+	}
+}
@@ -3,46 +3,85 @@ package cmd
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
-	"github.com/alec-rabold/zipspy/pkg/zipspy"
+	"github.com/alec-rabold/zipspy/pkg/reader"
 	"github.com/spf13/cobra"
 )
 
 func List() *cobra.Command {
 	var outFileName string
 	var includeDirectoryNames bool
+	var format string
 	cmd := &cobra.Command{
-		Use:   "list [--include-directory-names]",
+		Use:   "list [--include-directory-names] [--format name|long|json|jsonl|csv]",
 		Short: "List all file names from a zip archive.",
 		Long: `Prints out the names of all files contained within a zip archive.
+
+Use the "--format" flag to include details such as sizes, compression method, CRC-32,
+modification time, mode and comment:
+
+	zipspy list --location file://archive.zip --format long
+	zipspy list --location file://archive.zip --format json
+
+Use "--location -" to read the archive from the standard input, e.g. a pipe. The files are then
+read in order from their local headers, so comments and modes aren't known:
+
+	curl -s https://example.com/archive.zip | zipspy list --location -
 `,
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			if err := cmd.Parent().PersistentPreRunE(cmd.Parent(), args); err != nil {
 				return fmt.Errorf("root pre-run failed: %v", err)
 			}
+			if !contains(listFormats, format) {
+				cmd.Usage()
+				return fmt.Errorf("validation failed: unsupported format %q (supported: %s)", format, strings.Join(listFormats, ", "))
+			}
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			zip, err := zipspy.NewClient(cfg.zipReader)
-			if err != nil {
-				return fmt.Errorf("failed to create zipspy client: %v", err)
+			defer cfg.closeReader()
+			var all []*reader.File
+			if cfg.archiveLocation == stdinLocation {
+				var err error
+				if all, err = streamFiles(os.Stdin); err != nil {
+					return fmt.Errorf("failed to read archive from standard input: %w", err)
+				}
+			} else {
+				zip, err := cfg.newClient()
+				if err != nil {
+					return fmt.Errorf("failed to create zipspy client: %v", err)
+				}
+				all = zip.AllFiles()
 			}
 
 			outFile := os.Stdout
 			if outFileName != "" {
-				outFile, err = os.OpenFile(outFileName, os.O_CREATE|os.O_WRONLY, 0644)
+				var err error
+				outFile, err = os.OpenFile(outFileName, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 				if err != nil {
 					return fmt.Errorf("failed to open file (name: %s): %w", outFileName, err)
 				}
 				defer outFile.Close()
 			}
-			for _, file := range zip.AllFiles() {
+			var files []*reader.File
+			for _, file := range all {
 				// Skip directory names from file name list (e.g. "my/dir/")
 				if !includeDirectoryNames && strings.HasSuffix(file.Name, "/") {
 					continue
 				}
+				files = append(files, file)
+			}
+			if format != formatName {
+				w := bufio.NewWriter(outFile)
+				if err := writeListing(w, format, files); err != nil {
+					return fmt.Errorf("failed writing listing: %w", err)
+				}
+				return w.Flush()
+			}
+			for _, file := range files {
 				r := strings.NewReader(file.Name)
 				if err := writeToFile(bufio.NewReader(r), bufio.NewWriter(outFile), buildSeparator(cmd)); err != nil {
 					return fmt.Errorf("failed writing contents to file: %w", err)
@@ -52,9 +91,27 @@ func List() *cobra.Command {
 			return nil
 		},
 	}
+	cmd.PersistentFlags().StringVar(&format, "format", formatName, "(optional) output format (name, long, json, jsonl, csv)")
 	cmd.PersistentFlags().StringVarP(&outFileName, "out", "o", "", "(optional) name of a file to write output to")
 	cmd.PersistentFlags().BoolVar(&includeDirectoryNames, "include-directory-names", false, "(optional) include the leaf names of directories")
 	cmd.PersistentFlags().String("separator", "", "(optional) separator when combining the output of multiple file names")
 	cmd.PersistentFlags().Bool("no-newlines", false, "(optional) omit the newlines appended to file names")
 	return cmd
 }
+
+// streamFiles returns the files of the archive read from r, in order, with their sizes and checksums.
+func streamFiles(r io.Reader) ([]*reader.File, error) {
+	sr := reader.NewStreamReader(r, cfg.readerOptions()...)
+	var files []*reader.File
+	for {
+		// Next skips the previous file, after which its sizes and checksum are known.
+		_, err := sr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, sr.File())
+	}
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestListStdin(t *testing.T) {
+	location := writeTestArchive(t, map[string]string{"a.txt": "alpha", "dir/": "", "dir/b.txt": "bravo"})
+	stdin := os.Stdin
+	defer func() { os.Stdin = stdin }()
+	open := func() {
+		f, err := os.Open(strings.TrimPrefix(location, "file://"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { f.Close() })
+		os.Stdin = f
+	}
+
+	open()
+	out, err := runCommand(t, "list", "--location", "-")
+	if want := "a.txt\ndir/b.txt\n"; err != nil || out != want {
+		t.Errorf("list --location - = %q, %v, want %q", out, err, want)
+	}
+	// Sizes of files written with a data descriptor are known once they have been read.
+	open()
+	out, err = runCommand(t, "list", "--location", "-", "--format", "csv")
+	if err != nil || !strings.Contains(out, "\ndir/b.txt,false,5,") {
+		t.Errorf("list --location - --format csv = %q, %v, want the size of dir/b.txt", out, err)
+	}
+
+	open()
+	if _, err := runCommand(t, "tree", "--location", "-"); err == nil || !strings.Contains(err.Error(), "standard input") {
+		t.Errorf("tree --location - error = %v, want an error about the standard input", err)
+	}
+}
+
+func TestListTruncatesOutput(t *testing.T) {
+	location := writeTestArchive(t, map[string]string{"a.txt": "alpha"})
+	out := filepath.Join(t.TempDir(), "out.txt")
+	if err := os.WriteFile(out, []byte("previous contents, longer than the new ones"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCommand(t, "list", "--location", location, "--no-directory-cache", "--out", out); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(out); err != nil || string(b) != "a.txt\n" {
+		t.Errorf("--out file = %q, %v, want %q", b, err, "a.txt\n")
+	}
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/alec-rabold/zipspy/pkg/extract"
+	"github.com/alec-rabold/zipspy/pkg/reader"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func Recover() *cobra.Command {
+	var destDir, out string
+	var unsafePaths bool
+	cmd := &cobra.Command{
+		Use:   "recover [--dest-dir DIR] [--out repaired.zip]",
+		Short: "Salvage the intact files of a corrupt or truncated zip archive.",
+		Long: `Searches a damaged zip archive (e.g. an interrupted upload or download, whose central
+directory is missing) for local file headers, and checks the contents of every file found
+against its checksum. Each file is printed with its status, the offset of its local header
+and its name, followed by the reason damaged files could not be recovered:
+
+	zipspy recover --location s3://my-bucket/partial-upload.zip
+
+Use the "--dest-dir" flag to extract the intact files, and "--out" to write them to a
+repaired archive with a new central directory (their compressed data is copied as is):
+
+	zipspy recover --location file://broken.zip --dest-dir ./recovered --out repaired.zip
+
+Files whose checksum can't be verified (such as encrypted files without the right password)
+are reported as damaged. The intact files are read a second time to extract them.
+`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := cmd.Parent().PersistentPreRunE(cmd.Parent(), args); err != nil {
+				return fmt.Errorf("root pre-run failed: %v", err)
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer cfg.closeReader()
+			size, err := cfg.zipReader.Size()
+			if err != nil {
+				return fmt.Errorf("failed to get size of archive: %v", err)
+			}
+			w := bufio.NewWriter(os.Stdout)
+			defer w.Flush()
+			var files []*reader.File
+			total := 0
+			rec := reader.NewRecoverer(cfg.zipReader, size, cfg.readerOptions()...)
+			for {
+				f, err := rec.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return fmt.Errorf("failed to search archive: %w", err)
+				}
+				total++
+				if _, err := io.Copy(io.Discard, rec); err != nil {
+					fmt.Fprintf(w, "damaged\t%d\t%s\t%v\n", f.HeaderOffset(), f.Name, err)
+					continue
+				}
+				fmt.Fprintf(w, "ok\t%d\t%s\n", f.HeaderOffset(), f.Name)
+				files = append(files, f)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			if len(files) == 0 {
+				return fmt.Errorf("no intact files found (damaged: %d)", total)
+			}
+			log.Infof("recovered %d of %d file(s)", len(files), total)
+
+			if destDir != "" {
+				if err := recoverToDir(files, destDir, unsafePaths); err != nil {
+					return err
+				}
+			}
+			if out != "" {
+				if err := recoverToArchive(files, out); err != nil {
+					return fmt.Errorf("failed to write repaired archive (path: %s): %w", out, err)
+				}
+			}
+			return nil
+		},
+	}
+	cmd.PersistentFlags().StringVar(&destDir, "dest-dir", "", "(optional) directory to extract the intact files into")
+	cmd.PersistentFlags().StringVarP(&out, "out", "o", "", "(optional) path of a repaired zip archive to write the intact files to")
+	cmd.PersistentFlags().BoolVar(&unsafePaths, "unsafe-paths", false, "(optional) allow --dest-dir entries with absolute paths, \"..\" traversal or symlinks leaving the destination (trusted archives only)")
+	return cmd
+}
+
+// recoverToDir extracts the files beneath dir, continuing past files that fail.
+func recoverToDir(files []*reader.File, dir string, unsafePaths bool) error {
+	var opts []extract.Option
+	if unsafePaths {
+		opts = append(opts, extract.WithUnsafePaths())
+	}
+	extractor := extract.NewExtractor(dir, opts...)
+	var errs []error
+	for _, f := range files {
+		if err := extractor.Extract(f); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, extractor.Finish()...)
+	var unsafePathErr *extract.UnsafePathError
+	for _, err := range errs {
+		log.Error(err)
+		errors.As(err, &unsafePathErr)
+	}
+	if unsafePathErr != nil {
+		log.Warn("refused to write outside of --dest-dir, use --unsafe-paths only if you trust this archive")
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to extract %d file(s)", len(errs))
+	}
+	return nil
+}
+
+// recoverToArchive copies the raw data of the files to a new archive at path.
+func recoverToArchive(files []*reader.File, path string) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	bw := bufio.NewWriter(out)
+	zw := reader.NewWriter(bw)
+	for _, f := range files {
+		if err := zw.Copy(f); err != nil {
+			return fmt.Errorf("failed to copy file (name: %s): %w", f.Name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alec-rabold/zipspy/pkg/reader"
+)
+
+func TestRecoverToArchive(t *testing.T) {
+	contents := map[string]string{
+		"a.txt": strings.Repeat("alpha ", 1000),
+		"b.txt": strings.Repeat("bravo ", 1000),
+		"c.txt": strings.Repeat("charlie ", 1000),
+	}
+	archive, err := os.ReadFile(strings.TrimPrefix(writeTestArchive(t, contents), "file://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := reader.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	offsets := make(map[string]int64)
+	for _, f := range zr.File {
+		offsets[f.Name] = f.HeaderOffset()
+	}
+
+	tests := []struct {
+		name    string
+		damaged []byte
+		ok      []string
+	}{
+		// Truncated in the middle of c.txt, without a central directory.
+		{name: "truncated", damaged: archive[:offsets["c.txt"]+60], ok: []string{"a.txt", "b.txt"}},
+		{
+			name: "corrupted",
+			damaged: func() []byte {
+				b := append([]byte(nil), archive...)
+				b[offsets["b.txt"]+60] ^= 0xff
+				return b
+			}(),
+			ok: []string{"a.txt", "c.txt"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			damaged, repaired := filepath.Join(dir, "damaged.zip"), filepath.Join(dir, "repaired.zip")
+			if err := os.WriteFile(damaged, tt.damaged, 0644); err != nil {
+				t.Fatal(err)
+			}
+			out, err := runCommand(t, "recover", "--location", "file://"+damaged, "--out", repaired)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Each file is printed with its status and the offset of its local header.
+			lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+			if len(lines) != len(offsets) {
+				t.Fatalf("recover printed %q, want a line per file", out)
+			}
+			for i, name := range []string{"a.txt", "b.txt", "c.txt"} {
+				prefix := fmt.Sprintf("damaged\t%d\t%s\t", offsets[name], name)
+				if contains(tt.ok, name) {
+					prefix = fmt.Sprintf("ok\t%d\t%s", offsets[name], name)
+				}
+				if !strings.HasPrefix(lines[i], prefix) {
+					t.Errorf("line %d = %q, want prefix %q", i, lines[i], prefix)
+				}
+			}
+
+			// The repaired archive has a central directory, and the intact files' checksums.
+			rc, err := reader.OpenReader(repaired)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rc.Close()
+			if len(rc.File) != len(tt.ok) {
+				t.Fatalf("repaired archive has %d files, want %d", len(rc.File), len(tt.ok))
+			}
+			for i, f := range rc.File {
+				if f.Name != tt.ok[i] {
+					t.Errorf("repaired file %d = %s, want %s", i, f.Name, tt.ok[i])
+				}
+				r, err := f.Open()
+				if err != nil {
+					t.Fatal(err)
+				}
+				b, err := io.ReadAll(r)
+				r.Close()
+				if err != nil || string(b) != contents[f.Name] {
+					t.Errorf("repaired %s: %d bytes, %v, want %d bytes", f.Name, len(b), err, len(contents[f.Name]))
+				}
+			}
+		})
+	}
+}
@@ -1,12 +1,22 @@
 package cmd
 
 import (
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
+	"github.com/alec-rabold/zipspy/pkg/cache"
 	"github.com/alec-rabold/zipspy/pkg/provider"
 	"github.com/alec-rabold/zipspy/pkg/provider/aws/s3"
+	"github.com/alec-rabold/zipspy/pkg/provider/azure/blob"
+	"github.com/alec-rabold/zipspy/pkg/provider/gcp/gcs"
+	"github.com/alec-rabold/zipspy/pkg/provider/http"
 	"github.com/alec-rabold/zipspy/pkg/provider/local"
+	"github.com/alec-rabold/zipspy/pkg/provider/sftp"
+	"github.com/alec-rabold/zipspy/pkg/reader"
 	"github.com/alec-rabold/zipspy/pkg/zipspy"
 	"github.com/sirupsen/logrus"
 	log "github.com/sirupsen/logrus"
@@ -15,9 +25,48 @@ import (
 
 var cfg config
 
+// defaultLimits guard against zip bombs without getting in the way of legitimate archives.
+// Deflate can't compress better than about 1032:1, so only bombs (or large files of a single repeated byte)
+// exceed the default ratio, which other methods aren't subject to (see reader.Limits.MaxRatio).
+var defaultLimits = reader.Limits{
+	MaxEntries:       1000000,
+	MaxDirectorySize: 256 << 20, // 256 MiB
+	MaxEntrySize:     64 << 30,  // 64 GiB
+	MaxTotalSize:     1 << 40,   // 1 TiB
+	MaxRatio:         1000,
+}
+
 type config struct {
 	development     bool
 	archiveLocation string
+	httpHeaders     []string
+	bearerToken     string
+	s3Endpoint      string
+	s3Region        string
+	s3Profile       string
+	s3PathStyle     bool
+	s3VersionID     string
+	s3RequesterPays bool
+	s3SSEKey        string
+	gcsEndpoint     string
+	gcsAnonymous    bool
+	azureAccount    string
+	azureKey        string
+	azureSASToken   string
+	azureConnString string
+	azureEndpoint   string
+	sftpKeyFiles    []string
+	sftpPassword    string
+	sftpKnownHosts  []string
+	sftpInsecure    bool
+	cacheBlockSize  int64
+	cacheMaxBlocks  int
+	cacheDir        string
+	noDirCache      bool
+	password        string
+	passwordFile    string
+	scanLocal       bool
+	limits          reader.Limits
 	zipReader       zipspy.Reader
 }
 
@@ -41,38 +90,277 @@ download a subset of files, search and retrieve files with regular expressions,
 		if err := cfg.initProvider(); err != nil {
 			return fmt.Errorf("failed to initialize provider: %v", err)
 		}
+		if err := cfg.initPassword(); err != nil {
+			return fmt.Errorf("failed to read password: %v", err)
+		}
 		if err := setupLogger(verbosity); err != nil {
 			return fmt.Errorf("failed to initialize logger: %v", err)
 		}
 		return nil
 	}
 	cmd.PersistentFlags().BoolVar(&cfg.development, "development", false, "whether or not to use development settings")
-	cmd.PersistentFlags().StringVar(&cfg.archiveLocation, "location", "", `(required) protocol and address of your ZIP archive ("file://archive.zip", "s3://<bucket_name>/archive.zip")`)
+	cmd.PersistentFlags().StringVar(&cfg.archiveLocation, "location", "", `(required) protocol and address of your ZIP archive ("file://archive.zip", "s3://<bucket_name>/archive.zip", "gs://<bucket_name>/archive.zip", "az://<container_name>/archive.zip", "sftp://user@host/path/archive.zip", "https://example.com/archive.zip", or "-" for the standard input of the list command)`)
+	cmd.PersistentFlags().StringSliceVar(&cfg.httpHeaders, "header", []string{}, `(optional) extra header(s) to send with HTTP(S) requests (e.g. "X-Api-Key: secret")`)
+	cmd.PersistentFlags().StringVar(&cfg.bearerToken, "bearer-token", os.Getenv("ZIPSPY_BEARER_TOKEN"), "(optional) bearer token to send with HTTP(S) requests (default $ZIPSPY_BEARER_TOKEN)")
+	cmd.PersistentFlags().StringVar(&cfg.s3Endpoint, "s3-endpoint", os.Getenv("ZIPSPY_S3_ENDPOINT"), "(optional) endpoint URL of an S3-compatible server, e.g. \"http://localhost:9000\" (default $ZIPSPY_S3_ENDPOINT)")
+	cmd.PersistentFlags().StringVar(&cfg.s3Region, "s3-region", "", "(optional) AWS region of the S3 bucket (default $AWS_REGION or the shared config)")
+	cmd.PersistentFlags().StringVar(&cfg.s3Profile, "s3-profile", "", "(optional) AWS shared config profile (default $AWS_PROFILE)")
+	cmd.PersistentFlags().BoolVar(&cfg.s3PathStyle, "s3-path-style", os.Getenv("ZIPSPY_S3_PATH_STYLE") == "true", "(optional) use path-style S3 requests, as most S3-compatible servers require (default $ZIPSPY_S3_PATH_STYLE)")
+	cmd.PersistentFlags().StringVar(&cfg.s3VersionID, "s3-version-id", "", "(optional) version of the S3 object to read, instead of the latest one")
+	cmd.PersistentFlags().BoolVar(&cfg.s3RequesterPays, "s3-requester-pays", false, "(optional) acknowledge that requests to a requester-pays S3 bucket are charged to you")
+	cmd.PersistentFlags().StringVar(&cfg.s3SSEKey, "s3-sse-customer-key", os.Getenv("ZIPSPY_S3_SSE_CUSTOMER_KEY"), "(optional) base64-encoded 256-bit key of an S3 object encrypted with SSE-C (default $ZIPSPY_S3_SSE_CUSTOMER_KEY)")
+	cmd.PersistentFlags().StringVar(&cfg.gcsEndpoint, "gcs-endpoint", "", "(optional) endpoint URL of a GCS-compatible server, e.g. \"http://localhost:4443\" (default $STORAGE_EMULATOR_HOST or https://storage.googleapis.com)")
+	cmd.PersistentFlags().BoolVar(&cfg.gcsAnonymous, "gcs-anonymous", false, "(optional) send GCS requests without credentials, e.g. for public buckets")
+	cmd.PersistentFlags().StringVar(&cfg.azureAccount, "azure-account", os.Getenv("AZURE_STORAGE_ACCOUNT"), "(optional) Azure storage account of az:// locations (default $AZURE_STORAGE_ACCOUNT)")
+	cmd.PersistentFlags().StringVar(&cfg.azureKey, "azure-account-key", os.Getenv("AZURE_STORAGE_KEY"), "(optional) key of the Azure storage account (default $AZURE_STORAGE_KEY)")
+	cmd.PersistentFlags().StringVar(&cfg.azureSASToken, "azure-sas-token", os.Getenv("AZURE_STORAGE_SAS_TOKEN"), "(optional) shared access signature for Azure blobs (default $AZURE_STORAGE_SAS_TOKEN)")
+	cmd.PersistentFlags().StringVar(&cfg.azureConnString, "azure-connection-string", os.Getenv("AZURE_STORAGE_CONNECTION_STRING"), "(optional) connection string of the Azure storage account, e.g. \"UseDevelopmentStorage=true\" for Azurite (default $AZURE_STORAGE_CONNECTION_STRING)")
+	cmd.PersistentFlags().StringVar(&cfg.azureEndpoint, "azure-endpoint", "", "(optional) blob endpoint URL of az:// locations, e.g. \"http://127.0.0.1:10000/devstoreaccount1\" (default https://<account>.blob.core.windows.net)")
+	cmd.PersistentFlags().StringSliceVar(&cfg.sftpKeyFiles, "sftp-key-file", []string{}, "(optional) private key(s) for SFTP, in addition to the keys of ssh-agent (default ~/.ssh/id_ed25519, id_ecdsa and id_rsa)")
+	cmd.PersistentFlags().StringVar(&cfg.sftpPassword, "sftp-password", os.Getenv("ZIPSPY_SFTP_PASSWORD"), "(optional) password for SFTP (default $ZIPSPY_SFTP_PASSWORD)")
+	cmd.PersistentFlags().StringSliceVar(&cfg.sftpKnownHosts, "sftp-known-hosts", []string{}, "(optional) known_hosts file(s) to verify SFTP host keys against (default ~/.ssh/known_hosts)")
+	cmd.PersistentFlags().BoolVar(&cfg.sftpInsecure, "sftp-insecure-ignore-host-key", false, "(optional) accept any SFTP host key, which is vulnerable to man-in-the-middle attacks (testing only)")
+	cmd.PersistentFlags().Int64Var(&cfg.cacheBlockSize, "cache-block-size", 0, "(optional) cache reads in memory using aligned blocks of this many bytes (e.g. 1048576), disabled when 0")
+	cmd.PersistentFlags().IntVar(&cfg.cacheMaxBlocks, "cache-max-blocks", cache.DefaultMaxBlocks, "(optional) maximum number of blocks kept in memory when --cache-block-size is set")
+	cmd.PersistentFlags().StringVar(&cfg.cacheDir, "cache-dir", "", "(optional) directory for cached central directories and file indexes (default $XDG_CACHE_HOME/zipspy)")
+	cmd.PersistentFlags().BoolVar(&cfg.noDirCache, "no-directory-cache", false, "(optional) always read the central directory from the archive instead of the on-disk cache")
+	cmd.PersistentFlags().StringVar(&cfg.password, "password", "", "(optional) password for encrypted files, read from $ZIPSPY_PASSWORD if not set")
+	cmd.PersistentFlags().StringVar(&cfg.passwordFile, "password-file", "", "(optional) file containing the password for encrypted files")
+	cmd.PersistentFlags().BoolVar(&cfg.scanLocal, "scan-local-headers", false, "(optional) list files from their local headers instead of the central directory, e.g. for truncated archives")
+	cmd.PersistentFlags().Uint64Var(&cfg.limits.MaxEntries, "max-entries", defaultLimits.MaxEntries, "(optional) maximum number of files in the archive, unlimited when 0")
+	cmd.PersistentFlags().Uint64Var(&cfg.limits.MaxDirectorySize, "max-directory-size", defaultLimits.MaxDirectorySize, "(optional) maximum size in bytes of the central directory, unlimited when 0")
+	cmd.PersistentFlags().Uint64Var(&cfg.limits.MaxEntrySize, "max-entry-size", defaultLimits.MaxEntrySize, "(optional) maximum number of bytes decompressed from a single file, unlimited when 0")
+	cmd.PersistentFlags().Uint64Var(&cfg.limits.MaxTotalSize, "max-total-size", defaultLimits.MaxTotalSize, "(optional) maximum number of bytes decompressed from all files combined, unlimited when 0")
+	cmd.PersistentFlags().Uint64Var(&cfg.limits.MaxRatio, "max-ratio", defaultLimits.MaxRatio, "(optional) maximum ratio of decompressed to compressed size of a Deflate or Deflate64 file, unlimited when 0")
 	cmd.LocalFlags().StringVar(&verbosity, "verbosity", logrus.WarnLevel.String(), "global log level (trace, debug, info, warn, error, fatal, panic)")
 	must(cmd.MarkPersistentFlagRequired("location"))
 
 	cmd.AddCommand(List())
 	cmd.AddCommand(Extract())
+	cmd.AddCommand(Search())
+	cmd.AddCommand(Grep())
+	cmd.AddCommand(Tree())
+	cmd.AddCommand(Du())
+	cmd.AddCommand(Cat())
+	cmd.AddCommand(Recover())
+	cmd.AddCommand(Verify())
 
 	return cmd
 }
 
+// stdinLocation is the location of an archive read from the standard input, which only the list command supports.
+const stdinLocation = "-"
+
+var errStdinNotSupported = errors.New("only the list command can read the archive from standard input (--location -)")
+
+// stdinReader stands in for the reader of an archive read from the standard input,
+// failing the commands that need random access to it.
+type stdinReader struct{}
+
+func (stdinReader) Size() (int64, error)                    { return 0, errStdinNotSupported }
+func (stdinReader) ReadAt(p []byte, off int64) (int, error) { return 0, errStdinNotSupported }
+
 func (c *config) initProvider() error {
 	if c.archiveLocation == "" {
 		return fmt.Errorf("location must not be empty")
 	}
+	if c.archiveLocation == stdinLocation {
+		c.zipReader = stdinReader{}
+		return nil
+	}
+	httpOpts, err := c.httpOptions()
+	if err != nil {
+		return err
+	}
+	s3Opts, err := c.s3Options()
+	if err != nil {
+		return err
+	}
+	azureOpts := c.azureOptions()
 	r := provider.NewRegistry(
-		provider.WithProvider("s3", "s3://", s3.NewClient),
+		provider.WithProvider("s3", "s3://", s3.NewCreator(s3Opts...)),
+		provider.WithProvider("gcs", "gs://", gcs.NewCreator(c.gcsOptions()...)),
+		provider.WithProvider("azure", "az://", blob.NewCreator(azureOpts...)),
+		provider.WithMatcher("azure-url", blob.IsBlobURL, blob.NewURLCreator(azureOpts...)),
 		provider.WithProvider("local", "file://", local.NewClient),
+		provider.WithProvider("sftp", "sftp://", sftp.NewCreator(c.sftpOptions()...)),
+		provider.WithProvider("http", "http://", http.NewCreator("http", httpOpts...)),
+		provider.WithProvider("https", "https://", http.NewCreator("https", httpOpts...)),
 	)
 	zr, err := r.GetPlugin(c.archiveLocation)
 	if err != nil {
 		return fmt.Errorf("failed to get plugin for location %s: %w", c.archiveLocation, err)
 	}
+	if c.cacheBlockSize > 0 {
+		zr = cache.NewBlockReader(zr, cache.WithBlockSize(c.cacheBlockSize), cache.WithMaxBlocks(c.cacheMaxBlocks))
+	}
 	c.zipReader = zr
 	return nil
 }
 
+// initPassword resolves the password from the --password and --password-file flags,
+// or the ZIPSPY_PASSWORD environment variable.
+func (c *config) initPassword() error {
+	if c.password != "" && c.passwordFile != "" {
+		return fmt.Errorf("only one of --password or --password-file may be specified")
+	}
+	if c.passwordFile != "" {
+		b, err := os.ReadFile(c.passwordFile)
+		if err != nil {
+			return err
+		}
+		// Ignore the trailing newline most editors add.
+		c.password = strings.TrimRight(string(b), "\r\n")
+		return nil
+	}
+	if c.password == "" {
+		c.password = os.Getenv("ZIPSPY_PASSWORD")
+	}
+	return nil
+}
+
+// newClient creates a zipspy client for the archive, warning if the central directory
+// couldn't be read and the files were found by scanning local file headers instead.
+func (c *config) newClient() (*zipspy.Client, error) {
+	zip, err := zipspy.NewClient(c.zipReader, c.clientOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	if zip.ScannedLocalHeaders() && !c.scanLocal {
+		log.Warnf("central directory not found, files were read from local file headers (location: %s)", c.archiveLocation)
+	}
+	return zip, nil
+}
+
+// closeReader closes the reader of the archive if it implements io.Closer (e.g. an SFTP connection).
+func (c *config) closeReader() {
+	if closer, ok := c.zipReader.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Debugf("failed to close reader (location: %s): %v", c.archiveLocation, err)
+		}
+	}
+}
+
+// readerOptions returns the options for commands that read the archive without a zipspy client.
+func (c *config) readerOptions() []reader.Option {
+	opts := []reader.Option{reader.WithLimits(c.limits)}
+	if c.password != "" {
+		opts = append(opts, reader.WithPassword(c.password))
+	}
+	return opts
+}
+
+func (c *config) clientOptions() []zipspy.Option {
+	opts := []zipspy.Option{zipspy.WithLimits(c.limits)}
+	if c.scanLocal {
+		opts = append(opts, zipspy.WithScanLocalHeaders())
+	}
+	if c.password != "" {
+		opts = append(opts, zipspy.WithPassword(c.password))
+	}
+	if !c.noDirCache {
+		dir := c.cacheDir
+		if dir == "" {
+			var err error
+			if dir, err = cache.DefaultDir(); err != nil {
+				log.Debugf("directory cache disabled: %v", err)
+			}
+		}
+		if dir != "" {
+			opts = append(opts, zipspy.WithDirectoryCache(cache.NewDirectoryCache(dir), c.archiveLocation))
+		}
+	}
+	return opts
+}
+
+// s3Options returns the options set by flags, which the query parameters of an "s3://" location override.
+func (c *config) s3Options() ([]s3.Option, error) {
+	opts := []s3.Option{s3.WithPathStyle(c.s3PathStyle), s3.WithRequesterPays(c.s3RequesterPays)}
+	if c.s3Endpoint != "" {
+		opts = append(opts, s3.WithEndpoint(c.s3Endpoint))
+	}
+	if c.s3Region != "" {
+		opts = append(opts, s3.WithRegion(c.s3Region))
+	}
+	if c.s3Profile != "" {
+		opts = append(opts, s3.WithProfile(c.s3Profile))
+	}
+	if c.s3VersionID != "" {
+		opts = append(opts, s3.WithVersionID(c.s3VersionID))
+	}
+	if c.s3SSEKey != "" {
+		key, err := base64.StdEncoding.DecodeString(c.s3SSEKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SSE-C key, expected base64: %w", err)
+		}
+		opts = append(opts, s3.WithSSECustomerKey(string(key)))
+	}
+	return opts, nil
+}
+
+func (c *config) gcsOptions() []gcs.Option {
+	var opts []gcs.Option
+	if c.gcsEndpoint != "" {
+		opts = append(opts, gcs.WithEndpoint(c.gcsEndpoint))
+	}
+	if c.gcsAnonymous {
+		opts = append(opts, gcs.WithoutAuthentication())
+	}
+	return opts
+}
+
+func (c *config) azureOptions() []blob.Option {
+	var opts []blob.Option
+	if c.azureConnString != "" {
+		opts = append(opts, blob.WithConnectionString(c.azureConnString))
+	}
+	if c.azureAccount != "" {
+		opts = append(opts, blob.WithAccount(c.azureAccount))
+	}
+	if c.azureKey != "" {
+		opts = append(opts, blob.WithAccountKey(c.azureKey))
+	}
+	if c.azureSASToken != "" {
+		opts = append(opts, blob.WithSASToken(c.azureSASToken))
+	}
+	if c.azureEndpoint != "" {
+		opts = append(opts, blob.WithEndpoint(c.azureEndpoint))
+	}
+	return opts
+}
+
+func (c *config) sftpOptions() []sftp.Option {
+	var opts []sftp.Option
+	for _, path := range c.sftpKeyFiles {
+		opts = append(opts, sftp.WithKeyFile(path))
+	}
+	if c.sftpPassword != "" {
+		opts = append(opts, sftp.WithPassword(c.sftpPassword))
+	}
+	for _, path := range c.sftpKnownHosts {
+		opts = append(opts, sftp.WithKnownHostsFile(path))
+	}
+	if c.sftpInsecure {
+		opts = append(opts, sftp.WithInsecureIgnoreHostKey())
+	}
+	return opts
+}
+
+func (c *config) httpOptions() ([]http.Option, error) {
+	var opts []http.Option
+	for _, header := range c.httpHeaders {
+		kv := strings.SplitN(header, ":", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid header %q, expected \"Key: Value\"", header)
+		}
+		opts = append(opts, http.WithHeader(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])))
+	}
+	if c.bearerToken != "" {
+		opts = append(opts, http.WithBearerToken(c.bearerToken))
+	}
+	return opts, nil
+}
+
 func setupLogger(verbosity string) error {
 	log.SetOutput(os.Stdout)
 	level, err := logrus.ParseLevel(verbosity)
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/alec-rabold/zipspy/pkg/zipspy"
+	"github.com/spf13/cobra"
+)
+
+func Search() *cobra.Command {
+	var outFileName string
+	var includeDirectoryNames bool
+	var globs, excludes []string
+	cmd := &cobra.Command{
+		Use:   "search [REGEX] [--glob \"path/**/*.json\"] [--exclude \"path/**/test/**\"]",
+		Short: "Search for file names in a zip archive.",
+		Long: `Prints out the names of files within a zip archive matching a regular expression and/or glob patterns.
+
+To search with a regular expression:
+
+	zipspy search --location file://archive.zip '\.log$'
+
+To search with glob patterns, where "**" matches any number of directories:
+
+	zipspy search --location file://archive.zip --glob 'logs/**/*.log' --glob '*.txt'
+
+Use "--exclude" to skip files matching glob patterns:
+
+	zipspy search --location file://archive.zip --glob 'logs/**' --exclude 'logs/debug/**'
+`,
+		Args: cobra.MaximumNArgs(1),
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := cmd.Parent().PersistentPreRunE(cmd.Parent(), args); err != nil {
+				return fmt.Errorf("root pre-run failed: %v", err)
+			}
+			if len(args) == 0 && len(globs) == 0 {
+				cmd.Usage()
+				return fmt.Errorf("validation failed: a regular expression or at least one --glob pattern must be specified")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer cfg.closeReader()
+			zip, err := cfg.newClient()
+			if err != nil {
+				return fmt.Errorf("failed to create zipspy client: %v", err)
+			}
+
+			files := zip.AllFiles()
+			if len(args) == 1 {
+				re, err := regexp.Compile(args[0])
+				if err != nil {
+					return fmt.Errorf("invalid regular expression %q: %w", args[0], err)
+				}
+				files = zip.SearchFiles(*re)
+			}
+			if files, err = zipspy.FilterFiles(files, globs, excludes); err != nil {
+				return err
+			}
+
+			outFile := os.Stdout
+			if outFileName != "" {
+				outFile, err = os.OpenFile(outFileName, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+				if err != nil {
+					return fmt.Errorf("failed to open file (name: %s): %w", outFileName, err)
+				}
+				defer outFile.Close()
+			}
+			for _, file := range files {
+				// Skip directory names from file name list (e.g. "my/dir/")
+				if !includeDirectoryNames && strings.HasSuffix(file.Name, "/") {
+					continue
+				}
+				r := strings.NewReader(file.Name)
+				if err := writeToFile(bufio.NewReader(r), bufio.NewWriter(outFile), buildSeparator(cmd)); err != nil {
+					return fmt.Errorf("failed writing contents to file: %w", err)
+				}
+			}
+
+			return nil
+		},
+	}
+	cmd.PersistentFlags().StringSliceVar(&globs, "glob", []string{}, "(optional) glob patterns file names must match (e.g. \"logs/**/*.log\")")
+	cmd.PersistentFlags().StringSliceVar(&excludes, "exclude", []string{}, "(optional) glob patterns of file names to skip")
+	cmd.PersistentFlags().StringVarP(&outFileName, "out", "o", "", "(optional) name of a file to write output to")
+	cmd.PersistentFlags().BoolVar(&includeDirectoryNames, "include-directory-names", false, "(optional) include the names of matching directories")
+	cmd.PersistentFlags().String("separator", "", "(optional) separator when combining the output of multiple file names")
+	cmd.PersistentFlags().Bool("no-newlines", false, "(optional) omit the newlines appended to file names")
+	return cmd
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	location := writeTestArchive(t, map[string]string{
+		"README.md":            "",
+		"data/":                "",
+		"data/a.json":          "",
+		"logs/app.log":         "",
+		"logs/debug/trace.log": "",
+	})
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{args: []string{`\.log$`}, want: "logs/app.log\nlogs/debug/trace.log\n"},
+		{args: []string{"--glob", "*.md", "--glob", "data/**"}, want: "README.md\ndata/a.json\n"},
+		{args: []string{"--glob", "data/**", "--include-directory-names"}, want: "data/\ndata/a.json\n"},
+		{args: []string{`\.log$`, "--exclude", "**/debug/**"}, want: "logs/app.log\n"},
+		{args: []string{`^logs/`, "--glob", "**/*.log", "--exclude", "logs/app.log"}, want: "logs/debug/trace.log\n"},
+		{args: []string{`\.log$`, "--separator", "--", "--no-newlines"}, want: "logs/app.log--logs/debug/trace.log--"},
+		{args: []string{`\.txt$`}, want: ""},
+	}
+	for _, tt := range tests {
+		out, err := runCommand(t, append([]string{"search", "--location", location, "--no-directory-cache"}, tt.args...)...)
+		if err != nil || out != tt.want {
+			t.Errorf("search %q = %q, %v, want %q", tt.args, out, err, tt.want)
+		}
+	}
+}
+
+func TestSearchTruncatesOutput(t *testing.T) {
+	location := writeTestArchive(t, map[string]string{"a.txt": ""})
+	out := filepath.Join(t.TempDir(), "out.txt")
+	if err := os.WriteFile(out, []byte("previous contents, longer than the new ones"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCommand(t, "search", "--location", location, "--no-directory-cache", "--out", out, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(out); err != nil || string(b) != "a.txt\n" {
+		t.Errorf("--out file = %q, %v, want %q", b, err, "a.txt\n")
+	}
+}
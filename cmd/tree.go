@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func Tree() *cobra.Command {
+	var depth int
+	cmd := &cobra.Command{
+		Use:   "tree [--depth N] [PATH]",
+		Short: "Print the directory hierarchy of a zip archive.",
+		Long: `Prints the files and directories within a zip archive as a tree,
+starting at the root of the archive or the given directory:
+
+	zipspy tree --location file://archive.zip
+	zipspy tree --location file://archive.zip --depth 2 path/to
+`,
+		Args: cobra.MaximumNArgs(1),
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := cmd.Parent().PersistentPreRunE(cmd.Parent(), args); err != nil {
+				return fmt.Errorf("root pre-run failed: %v", err)
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer cfg.closeReader()
+			root, err := fsPathArg(args)
+			if err != nil {
+				return err
+			}
+			zip, err := cfg.newClient()
+			if err != nil {
+				return fmt.Errorf("failed to create zipspy client: %v", err)
+			}
+			if _, err := fs.ReadDir(zip.FS(), root); err != nil {
+				return fmt.Errorf("failed to read directory (path: %s): %w", root, err)
+			}
+
+			w := bufio.NewWriter(os.Stdout)
+			defer w.Flush()
+			fmt.Fprintln(w, root)
+			t := &treePrinter{fsys: zip.FS(), w: w, maxDepth: depth}
+			if err := t.print(root, "", 1); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "\n%d directories, %d files\n", t.dirs, t.files)
+			return nil
+		},
+	}
+	cmd.PersistentFlags().IntVar(&depth, "depth", -1, "(optional) maximum depth of directories to descend into, unlimited when negative")
+	return cmd
+}
+
+type treePrinter struct {
+	fsys     fs.FS
+	w        io.Writer
+	maxDepth int
+	dirs     int
+	files    int
+}
+
+func (t *treePrinter) print(dir, prefix string, depth int) error {
+	if t.maxDepth >= 0 && depth > t.maxDepth {
+		return nil
+	}
+	entries, err := fs.ReadDir(t.fsys, dir)
+	if err != nil {
+		return fmt.Errorf("failed to read directory (path: %s): %w", dir, err)
+	}
+	for i, entry := range entries {
+		branch, indent := "├── ", "│   "
+		if i == len(entries)-1 {
+			branch, indent = "└── ", "    "
+		}
+		fmt.Fprintf(t.w, "%s%s%s\n", prefix, branch, entry.Name())
+		if !entry.IsDir() {
+			t.files++
+			continue
+		}
+		t.dirs++
+		if err := t.print(joinFSPath(dir, entry.Name()), prefix+indent, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fsPathArg returns the directory given as an optional argument as a valid fs.FS path.
+func fsPathArg(args []string) (string, error) {
+	if len(args) == 0 {
+		return ".", nil
+	}
+	p := strings.Trim(args[0], "/")
+	if p == "" {
+		return ".", nil
+	}
+	if !fs.ValidPath(p) {
+		return "", fmt.Errorf("invalid path: %q", args[0])
+	}
+	return p, nil
+}
+
+func joinFSPath(dir, name string) string {
+	if dir == "." {
+		return name
+	}
+	return dir + "/" + name
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/alec-rabold/zipspy/pkg/reader"
+)
+
+// treeContents are the files of the archive used to test tree and du.
+var treeContents = map[string]string{
+	"README.md":                "# readme\n",
+	"docs/guide.txt":           "a guide to everything\n",
+	"docs/api/ref.txt":         "reference\n",
+	"src/main.go":              strings.Repeat("package main\n", 100),
+	"src/util/strings.go":      strings.Repeat("package util\n", 50),
+	"src/util/strings_test.go": "package util\n",
+}
+
+// newTestReader returns a reader of an archive holding the given contents, whose directories are derived from file names.
+func newTestReader(t *testing.T, contents map[string]string) *reader.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range contents {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(data))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := reader.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zr
+}
+
+func TestTree(t *testing.T) {
+	zr := newTestReader(t, treeContents)
+	tests := []struct {
+		name  string
+		root  string
+		depth int
+		want  string
+		dirs  int
+		files int
+	}{
+		{
+			name:  "whole archive",
+			root:  ".",
+			depth: -1,
+			want: `├── README.md
+├── docs
+│   ├── api
+│   │   └── ref.txt
+│   └── guide.txt
+└── src
+    ├── main.go
+    └── util
+        ├── strings.go
+        └── strings_test.go
+`,
+			dirs:  4,
+			files: 6,
+		},
+		{
+			name:  "depth",
+			root:  ".",
+			depth: 1,
+			want: `├── README.md
+├── docs
+└── src
+`,
+			dirs:  2,
+			files: 1,
+		},
+		{
+			name:  "path",
+			root:  "src",
+			depth: -1,
+			want: `├── main.go
+└── util
+    ├── strings.go
+    └── strings_test.go
+`,
+			dirs:  1,
+			files: 3,
+		},
+		{
+			name:  "path and depth",
+			root:  "docs",
+			depth: 1,
+			want: `├── api
+└── guide.txt
+`,
+			dirs:  1,
+			files: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			p := &treePrinter{fsys: zr, w: &out, maxDepth: tt.depth}
+			if err := p.print(tt.root, "", 1); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("tree =\n%s\nwant\n%s", out.String(), tt.want)
+			}
+			if p.dirs != tt.dirs || p.files != tt.files {
+				t.Errorf("%d directories, %d files, want %d, %d", p.dirs, p.files, tt.dirs, tt.files)
+			}
+		})
+	}
+}
+
+func TestFSPathArg(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+		ok   bool
+	}{
+		{args: nil, want: ".", ok: true},
+		{args: []string{"/"}, want: ".", ok: true},
+		{args: []string{"/src/util/"}, want: "src/util", ok: true},
+		{args: []string{"src/../.."}, ok: false},
+	}
+	for _, tt := range tests {
+		got, err := fsPathArg(tt.args)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("fsPathArg(%q) = %q, %v, want %q (ok: %v)", tt.args, got, err, tt.want, tt.ok)
+		}
+	}
+}
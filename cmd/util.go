@@ -2,7 +2,10 @@ package cmd
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"io"
+	"os"
 
 	"github.com/spf13/cobra"
 )
@@ -41,3 +44,119 @@ func writeToFile(r *bufio.Reader, w *bufio.Writer, separator string) error {
 	}
 	return nil
 }
+
+// errLineTooLong is returned by readLine for lines exceeding the maximum length.
+var errLineTooLong = errors.New("line too long")
+
+// readLine returns the next line of br, including its newline, without reading more than max bytes into memory.
+// The rest of a longer line is skipped, and errLineTooLong returned.
+func readLine(br *bufio.Reader, max int) ([]byte, error) {
+	var line []byte
+	tooLong := false
+	for {
+		chunk, err := br.ReadSlice('\n')
+		if !tooLong && len(line)+len(chunk) > max {
+			line, tooLong = nil, true
+		}
+		if !tooLong {
+			line = append(line, chunk...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if tooLong && (err == nil || err == io.EOF) {
+			return nil, errLineTooLong
+		}
+		return line, err
+	}
+}
+
+// spoolToTempFile copies r to a new temporary file and returns it rewound for reading.
+// The file is removed when it is closed.
+func spoolToTempFile(r io.Reader) (io.ReadCloser, error) {
+	f, err := os.CreateTemp("", "zipspy-*")
+	if err != nil {
+		return nil, err
+	}
+	spool := &tempFile{f}
+	if _, err := io.Copy(f, r); err != nil {
+		spool.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		spool.Close()
+		return nil, err
+	}
+	return spool, nil
+}
+
+// maxSpoolMemory is the number of bytes a spoolWriter holds in memory before moving them to a temporary file.
+const maxSpoolMemory = 64 << 10 // 64 KiB
+
+// spoolWriter holds what is written to it in memory, up to maxSpoolMemory bytes, and in a temporary file beyond.
+// Its contents are read back with Reader.
+type spoolWriter struct {
+	buf  bytes.Buffer
+	file *tempFile
+	w    *bufio.Writer // buffered writer of file
+}
+
+func (s *spoolWriter) Write(p []byte) (int, error) {
+	if s.file == nil {
+		if s.buf.Len()+len(p) <= maxSpoolMemory {
+			return s.buf.Write(p)
+		}
+		f, err := os.CreateTemp("", "zipspy-*")
+		if err != nil {
+			return 0, err
+		}
+		s.file = &tempFile{f}
+		s.w = bufio.NewWriter(f)
+		if _, err := s.buf.WriteTo(s.w); err != nil {
+			return 0, err
+		}
+	}
+	return s.w.Write(p)
+}
+
+// Reader returns a reader of everything written so far, which releases the spool when closed.
+func (s *spoolWriter) Reader() (io.ReadCloser, error) {
+	if s.file == nil {
+		return io.NopCloser(&s.buf), nil
+	}
+	if err := s.w.Flush(); err != nil {
+		return nil, err
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return s.file, nil
+}
+
+// Close releases the spool without reading it.
+func (s *spoolWriter) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Close()
+}
+
+// tempFile is a file that is removed when closed.
+type tempFile struct {
+	*os.File
+}
+
+func (f *tempFile) Close() error {
+	err := f.File.Close()
+	os.Remove(f.Name())
+	return err
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/alec-rabold/zipspy/pkg/reader"
+	"github.com/spf13/cobra"
+)
+
+// Output formats supported by the verify command.
+const formatText = "text"
+
+var verifyFormats = []string{formatText, formatJSON}
+
+// verifyReport is the schema of the report printed by the verify command in the json format.
+// Fields may be added in the future, but existing fields will not be renamed or removed.
+type verifyReport struct {
+	OK              bool            `json:"ok"`
+	Files           int             `json:"files"`
+	ContentsChecked bool            `json:"contents_checked"` // false with --headers-only
+	Problems        []verifyProblem `json:"problems"`
+}
+
+type verifyProblem struct {
+	Kind    string `json:"kind"`           // e.g. "local_header", "overlap" or "contents"
+	Name    string `json:"name,omitempty"` // empty for problems with the central directory
+	Offset  int64  `json:"offset"`
+	Message string `json:"message"`
+}
+
+func Verify() *cobra.Command {
+	var headersOnly bool
+	var format string
+	var workers int
+	cmd := &cobra.Command{
+		Use:   "verify [--headers-only] [--format text|json]",
+		Short: "Test the integrity of a zip archive, like \"unzip -t\".",
+		Long: `Checks that each central directory entry matches its local file header (name, method,
+flags, sizes and CRC-32) and data descriptor, that no two files overlap and that no bytes
+are left between them, and that every file can be decompressed and matches its CRC-32:
+
+	zipspy verify --location s3://my-bucket/archive.zip
+	zipspy verify --location s3://my-bucket/archive.zip --format json
+
+Each problem found is printed, and the command exits with a non-zero status if there are any.
+The central directory is always read from the archive, ignoring the cache.
+
+Use "--headers-only" to skip decompressing the files, which only reads the headers:
+
+	zipspy verify --location s3://my-bucket/archive.zip --headers-only
+`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := cmd.Parent().PersistentPreRunE(cmd.Parent(), args); err != nil {
+				return fmt.Errorf("root pre-run failed: %v", err)
+			}
+			if err := validateVerifyCommand(format, workers); err != nil {
+				cmd.Usage()
+				return fmt.Errorf("validation failed: %v", err)
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer cfg.closeReader()
+			// Problems with the archive are reported, not usage errors.
+			cmd.SilenceUsage = true
+			report := verifyReport{ContentsChecked: !headersOnly}
+			problems := verifyArchive(&report, headersOnly, workers)
+			for _, p := range problems {
+				report.Problems = append(report.Problems, verifyProblem{Kind: p.Kind, Name: p.Name, Offset: p.Offset, Message: p.Message})
+			}
+			report.OK = len(problems) == 0
+
+			w := bufio.NewWriter(os.Stdout)
+			if err := writeVerifyReport(w, format, &report); err != nil {
+				return err
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			if !report.OK {
+				return fmt.Errorf("found %d problem(s) (location: %s)", len(problems), cfg.archiveLocation)
+			}
+			return nil
+		},
+	}
+	cmd.PersistentFlags().BoolVar(&headersOnly, "headers-only", false, "(optional) only check the headers and layout of the archive, without decompressing files")
+	cmd.PersistentFlags().StringVar(&format, "format", formatText, "(optional) output format (text, json)")
+	cmd.PersistentFlags().IntVar(&workers, "workers", 1, "(optional) number of files to download and decompress concurrently")
+	return cmd
+}
+
+func validateVerifyCommand(format string, workers int) error {
+	if !contains(verifyFormats, format) {
+		return fmt.Errorf("unsupported format %q (supported: %s)", format, strings.Join(verifyFormats, ", "))
+	}
+	if workers < 1 {
+		return fmt.Errorf("--workers must be at least 1")
+	}
+	return nil
+}
+
+// verifyArchive reads the central directory of the archive and checks its files, setting the number of files in the report.
+func verifyArchive(report *verifyReport, headersOnly bool, workers int) []reader.Problem {
+	size, err := cfg.zipReader.Size()
+	if err != nil {
+		return []reader.Problem{{Kind: reader.ProblemDirectory, Message: fmt.Sprintf("failed to get size of archive: %v", err)}}
+	}
+	zr, err := reader.NewReader(cfg.zipReader, size, cfg.readerOptions()...)
+	if err != nil {
+		return []reader.Problem{{Kind: reader.ProblemDirectory, Message: fmt.Sprintf("failed to read central directory: %v", err)}}
+	}
+	report.Files = len(zr.File)
+	problems := zr.VerifyHeaders()
+	if headersOnly {
+		return problems
+	}
+
+	errs := make([]error, len(zr.File))
+	work := func(i int) error {
+		errs[i] = zr.File[i].Verify()
+		return nil
+	}
+	forEachOrdered(len(zr.File), workers, false, work, func(int) error { return nil })
+	for i, err := range errs {
+		if err != nil {
+			f := zr.File[i]
+			problems = append(problems, reader.Problem{Kind: reader.ProblemContents, Name: f.Name, Offset: f.HeaderOffset(), Message: err.Error()})
+		}
+	}
+	return problems
+}
+
+func writeVerifyReport(w io.Writer, format string, report *verifyReport) error {
+	if format == formatJSON {
+		if report.Problems == nil {
+			report.Problems = []verifyProblem{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	for _, p := range report.Problems {
+		name := p.Name
+		if name == "" {
+			name = "-"
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", p.Kind, p.Offset, name, p.Message); err != nil {
+			return err
+		}
+	}
+	if report.OK {
+		_, err := fmt.Fprintf(w, "No errors detected in %d file(s).\n", report.Files)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alec-rabold/zipspy/pkg/reader"
+)
+
+func TestVerifyReport(t *testing.T) {
+	location := writeTestArchive(t, map[string]string{
+		"a.txt": strings.Repeat("alpha ", 100),
+		"b.txt": strings.Repeat("bravo ", 100),
+	})
+	archive, err := os.ReadFile(strings.TrimPrefix(location, "file://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := reader.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Change a byte of b.txt's compressed data, which only reading its contents can find.
+	offset, err := zr.File[1].DataOffset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := filepath.Join(t.TempDir(), "corrupt.zip")
+	archive[offset+int64(zr.File[1].CompressedSize64)/2] ^= 0xff
+	if err := os.WriteFile(corrupt, archive, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		location string
+		args     []string
+		want     map[string]interface{}
+	}{
+		{
+			name:     "intact",
+			location: location,
+			want:     map[string]interface{}{"ok": true, "files": 2.0, "contents_checked": true, "problems": []interface{}{}},
+		},
+		{
+			name:     "corrupt",
+			location: "file://" + corrupt,
+			want: map[string]interface{}{"ok": false, "files": 2.0, "contents_checked": true, "problems": []interface{}{
+				map[string]interface{}{"kind": "contents", "name": "b.txt", "offset": float64(zr.File[1].HeaderOffset())},
+			}},
+		},
+		{
+			name:     "corrupt headers only",
+			location: "file://" + corrupt,
+			args:     []string{"--headers-only"},
+			want:     map[string]interface{}{"ok": true, "files": 2.0, "contents_checked": false, "problems": []interface{}{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := runCommand(t, append([]string{"verify", "--location", tt.location, "--format", "json"}, tt.args...)...)
+			// An error makes main exit with a non-zero status.
+			if ok := tt.want["ok"].(bool); (err == nil) != ok {
+				t.Errorf("verify error = %v, want an error: %v", err, !ok)
+			}
+			var report map[string]interface{}
+			if err := json.Unmarshal([]byte(out), &report); err != nil {
+				t.Fatalf("verify printed %q: %v", out, err)
+			}
+			// Messages are free-form, but must be present.
+			problems, _ := report["problems"].([]interface{})
+			for i, p := range problems {
+				p, _ := p.(map[string]interface{})
+				if message, _ := p["message"].(string); message == "" {
+					t.Errorf("problem %d has no message", i)
+				}
+				delete(p, "message")
+			}
+			if !reflect.DeepEqual(report, tt.want) {
+				t.Errorf("verify report = %v, want %v", report, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyText(t *testing.T) {
+	location := writeTestArchive(t, map[string]string{"a.txt": "alpha"})
+	out, err := runCommand(t, "verify", "--location", location)
+	if err != nil || out != "No errors detected in 1 file(s).\n" {
+		t.Errorf("verify = %q, %v, want no errors", out, err)
+	}
+
+	// Rename a.txt in its local header only.
+	archive, err := os.ReadFile(strings.TrimPrefix(location, "file://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive[30] = 'x'
+	corrupt := filepath.Join(t.TempDir(), "corrupt.zip")
+	if err := os.WriteFile(corrupt, archive, 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err = runCommand(t, "verify", "--location", "file://"+corrupt, "--headers-only")
+	if err == nil || !strings.HasPrefix(out, "local_header\t0\ta.txt\tname does not match") {
+		t.Errorf("verify of a mismatched local header = %q, %v, want a local_header problem and an error", out, err)
+	}
+}
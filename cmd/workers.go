@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"errors"
+	"sync"
+)
+
+// errSkipped marks items that were never started because a previous item failed.
+var errSkipped = errors.New("skipped after a previous failure")
+
+// forEachOrdered calls work for each index in [0, n) using up to the given number of workers.
+// Once an item's work has finished, and all items before it have been handled, done is called
+// for it from the calling goroutine, so done observes items in their original order.
+// Errors from work or done are collected and returned in order. If failFast is set,
+// no new work is started and done is no longer called after the first error.
+func forEachOrdered(n, workers int, failFast bool, work func(i int) error, done func(i int) error) []error {
+	if workers < 1 {
+		workers = 1
+	}
+	finished := make([]chan error, n)
+	for i := range finished {
+		finished[i] = make(chan error, 1)
+	}
+
+	stop := make(chan struct{})
+	jobs := make(chan int)
+	go func() {
+		defer close(jobs)
+		for i := 0; i < n; i++ {
+			select {
+			case jobs <- i:
+			case <-stop:
+				for ; i < n; i++ {
+					finished[i] <- errSkipped
+				}
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				finished[i] <- work(i)
+			}
+		}()
+	}
+
+	var errs []error
+	stopped := false
+	for i := 0; i < n; i++ {
+		err := <-finished[i]
+		if err == errSkipped {
+			continue
+		}
+		if err == nil && !stopped {
+			err = done(i)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			if failFast && !stopped {
+				stopped = true
+				close(stop)
+			}
+		}
+	}
+	wg.Wait()
+	return errs
+}
@@ -0,0 +1,264 @@
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/alec-rabold/zipspy/pkg/zipspy"
+)
+
+const (
+	// DefaultBlockSize is the default number of bytes fetched per block.
+	DefaultBlockSize = 1 << 20 // 1 MiB
+	// DefaultMaxBlocks is the default number of blocks kept in memory.
+	DefaultMaxBlocks = 64
+)
+
+var _ zipspy.Reader = (*BlockReader)(nil)
+var _ zipspy.Versioner = (*BlockReader)(nil)
+
+// BlockReader implements the zipspy.Reader interface by wrapping another reader
+// and caching its contents in fixed-size, aligned blocks.
+// Reads are rounded out to block boundaries, consecutive missing blocks are
+// fetched with a single read, and the most recently used blocks are kept in memory.
+// It is safe for concurrent use; concurrent reads of the same block only fetch it once.
+type BlockReader struct {
+	r         zipspy.Reader
+	blockSize int64
+	maxBlocks int
+
+	sizeOnce sync.Once
+	size     int64
+	sizeErr  error
+
+	mu       sync.Mutex
+	lru      *list.List // of *block, most recently used first
+	blocks   map[int64]*list.Element
+	inflight map[int64]*blockCall
+}
+
+type block struct {
+	index int64
+	data  []byte
+}
+
+// blockCall tracks a block that is currently being fetched.
+type blockCall struct {
+	wg   sync.WaitGroup
+	data []byte
+	err  error
+}
+
+// BlockOption configures a BlockReader.
+type BlockOption func(*BlockReader)
+
+// WithBlockSize sets the number of bytes fetched per block.
+func WithBlockSize(n int64) BlockOption {
+	return func(b *BlockReader) {
+		if n > 0 {
+			b.blockSize = n
+		}
+	}
+}
+
+// WithMaxBlocks sets the maximum number of blocks kept in memory.
+func WithMaxBlocks(n int) BlockOption {
+	return func(b *BlockReader) {
+		if n > 0 {
+			b.maxBlocks = n
+		}
+	}
+}
+
+// NewBlockReader wraps r with an in-memory LRU block cache.
+func NewBlockReader(r zipspy.Reader, opts ...BlockOption) *BlockReader {
+	b := &BlockReader{
+		r:         r,
+		blockSize: DefaultBlockSize,
+		maxBlocks: DefaultMaxBlocks,
+		lru:       list.New(),
+		blocks:    make(map[int64]*list.Element),
+		inflight:  make(map[int64]*blockCall),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Size returns the size of the underlying reader, which is only requested once.
+func (b *BlockReader) Size() (int64, error) {
+	b.sizeOnce.Do(func() {
+		b.size, b.sizeErr = b.r.Size()
+	})
+	return b.size, b.sizeErr
+}
+
+// Version returns the version of the underlying reader, if it implements zipspy.Versioner.
+func (b *BlockReader) Version() (string, error) {
+	v, ok := b.r.(zipspy.Versioner)
+	if !ok {
+		return "", fmt.Errorf("reader does not support versioning")
+	}
+	return v.Version()
+}
+
+// Close closes the underlying reader, if it implements io.Closer.
+func (b *BlockReader) Close() error {
+	if c, ok := b.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// ReadAt implements the io.ReaderAt interface by serving reads from cached blocks,
+// fetching any missing blocks from the underlying reader.
+func (b *BlockReader) ReadAt(p []byte, off int64) (n int, err error) {
+	size, err := b.Size()
+	if err != nil {
+		return 0, err
+	}
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset: %d", off)
+	}
+	if off >= size {
+		return 0, io.EOF
+	}
+	end := off + int64(len(p))
+	if end > size {
+		end = size
+	}
+	if end == off {
+		return 0, nil
+	}
+
+	first, last := off/b.blockSize, (end-1)/b.blockSize
+	data, err := b.getBlocks(first, last, size)
+	if err != nil {
+		return 0, err
+	}
+	for i, d := range data {
+		start := (first + int64(i)) * b.blockSize
+		lo, hi := int64(0), int64(len(d))
+		if start < off {
+			lo = off - start
+		}
+		if start+hi > end {
+			hi = end - start
+		}
+		n += copy(p[n:], d[lo:hi])
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// getBlocks returns the contents of blocks first through last (inclusive).
+func (b *BlockReader) getBlocks(first, last, size int64) ([][]byte, error) {
+	data := make([][]byte, last-first+1)
+	waits := make(map[int64]*blockCall)
+	owned := make(map[int64]*blockCall)
+
+	b.mu.Lock()
+	for idx := first; idx <= last; idx++ {
+		if el, ok := b.blocks[idx]; ok {
+			b.lru.MoveToFront(el)
+			data[idx-first] = el.Value.(*block).data
+			continue
+		}
+		if call, ok := b.inflight[idx]; ok {
+			waits[idx] = call
+			continue
+		}
+		call := &blockCall{}
+		call.wg.Add(1)
+		b.inflight[idx] = call
+		owned[idx] = call
+	}
+	b.mu.Unlock()
+
+	// Fetch each run of consecutive missing blocks with a single read.
+	var fetchErr error
+	for idx := first; idx <= last; idx++ {
+		if _, ok := owned[idx]; !ok {
+			continue
+		}
+		runEnd := idx
+		for runEnd+1 <= last && owned[runEnd+1] != nil {
+			runEnd++
+		}
+		if err := b.fetch(idx, runEnd, size, owned); err != nil && fetchErr == nil {
+			fetchErr = err
+		}
+		for i := idx; i <= runEnd; i++ {
+			data[i-first] = owned[i].data
+		}
+		idx = runEnd
+	}
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+
+	for idx, call := range waits {
+		call.wg.Wait()
+		if call.err != nil {
+			return nil, call.err
+		}
+		data[idx-first] = call.data
+	}
+	return data, nil
+}
+
+// fetch reads blocks first through last from the underlying reader,
+// stores them in the cache and completes their pending calls.
+func (b *BlockReader) fetch(first, last, size int64, calls map[int64]*blockCall) error {
+	start := first * b.blockSize
+	end := (last + 1) * b.blockSize
+	if end > size {
+		end = size
+	}
+	buf := make([]byte, end-start)
+	n, err := b.r.ReadAt(buf, start)
+	if err == io.EOF && n == len(buf) {
+		err = nil
+	}
+	if err == nil && n < len(buf) {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to read blocks (offset: %d) (length: %d): %w", start, len(buf), err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for idx := first; idx <= last; idx++ {
+		call := calls[idx]
+		call.err = err
+		if err == nil {
+			lo := (idx - first) * b.blockSize
+			hi := lo + b.blockSize
+			if hi > int64(len(buf)) {
+				hi = int64(len(buf))
+			}
+			call.data = buf[lo:hi:hi]
+			b.add(idx, call.data)
+		}
+		delete(b.inflight, idx)
+		call.wg.Done()
+	}
+	return err
+}
+
+// add inserts a block into the cache, evicting the least recently used blocks.
+// The caller must hold b.mu.
+func (b *BlockReader) add(idx int64, data []byte) {
+	b.blocks[idx] = b.lru.PushFront(&block{index: idx, data: data})
+	for b.lru.Len() > b.maxBlocks {
+		el := b.lru.Back()
+		b.lru.Remove(el)
+		delete(b.blocks, el.Value.(*block).index)
+	}
+}
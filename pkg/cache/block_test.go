@@ -0,0 +1,201 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeReader serves data and records the reads it is asked for.
+// Reads wait for release, if set, and fail with the next error of errs, if any.
+type fakeReader struct {
+	data    []byte
+	started chan struct{} // receives a value when a read starts, if set
+	release chan struct{}
+
+	mu    sync.Mutex
+	reads [][2]int64 // offset and length
+	errs  []error
+}
+
+func (r *fakeReader) Size() (int64, error) { return int64(len(r.data)), nil }
+
+func (r *fakeReader) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	r.reads = append(r.reads, [2]int64{off, int64(len(p))})
+	var err error
+	if len(r.errs) > 0 {
+		err, r.errs = r.errs[0], r.errs[1:]
+	}
+	r.mu.Unlock()
+	if r.started != nil {
+		r.started <- struct{}{}
+	}
+	if r.release != nil {
+		<-r.release
+	}
+	if err != nil {
+		return 0, err
+	}
+	n := copy(p, r.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// takeReads returns the reads since the last call.
+func (r *fakeReader) takeReads() [][2]int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reads := r.reads
+	r.reads = nil
+	return reads
+}
+
+func testData(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte('a' + i%26)
+	}
+	return data
+}
+
+func TestBlockAlignment(t *testing.T) {
+	data := testData(25)
+	r := &fakeReader{data: data}
+	b := NewBlockReader(r, WithBlockSize(10))
+
+	tests := []struct {
+		off     int64
+		length  int
+		want    []byte
+		wantErr error
+		reads   [][2]int64
+	}{
+		// Reads are rounded out to whole blocks, and consecutive missing blocks are read at once.
+		{off: 7, length: 5, want: data[7:12], reads: [][2]int64{{0, 20}}},
+		// The last block is partial, and a read past the end is short.
+		{off: 18, length: 10, want: data[18:], wantErr: io.EOF, reads: [][2]int64{{20, 5}}},
+		// Cached blocks aren't read again.
+		{off: 0, length: 25, want: data},
+		{off: 25, length: 1, want: []byte{}, wantErr: io.EOF},
+	}
+	for _, tt := range tests {
+		p := make([]byte, tt.length)
+		n, err := b.ReadAt(p, tt.off)
+		if err != tt.wantErr || !bytes.Equal(p[:n], tt.want) {
+			t.Errorf("ReadAt(%d bytes, %d) = %q, %v, want %q, %v", tt.length, tt.off, p[:n], err, tt.want, tt.wantErr)
+		}
+		if reads := r.takeReads(); !equalReads(reads, tt.reads) {
+			t.Errorf("ReadAt(%d bytes, %d) read %v, want %v", tt.length, tt.off, reads, tt.reads)
+		}
+	}
+}
+
+func equalReads(a, b [][2]int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestBlockEviction(t *testing.T) {
+	r := &fakeReader{data: testData(40)}
+	b := NewBlockReader(r, WithBlockSize(10), WithMaxBlocks(2))
+	tests := []struct {
+		block   int64
+		fetched bool
+	}{
+		{0, true},
+		{1, true},
+		{2, true}, // evicts 0
+		{1, false},
+		{0, true}, // evicts 2, less recently used than 1
+		{1, false},
+		{2, true},
+	}
+	p := make([]byte, 1)
+	for i, tt := range tests {
+		if _, err := b.ReadAt(p, tt.block*10); err != nil {
+			t.Fatal(err)
+		}
+		if fetched := len(r.takeReads()) > 0; fetched != tt.fetched {
+			t.Errorf("read %d of block %d: fetched = %v, want %v", i, tt.block, fetched, tt.fetched)
+		}
+	}
+}
+
+// readConcurrently starts n reads of the first block while its fetch is held,
+// and returns their errors once the fetch has been released.
+func readConcurrently(t *testing.T, b *BlockReader, r *fakeReader, n int) []error {
+	t.Helper()
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = b.ReadAt(make([]byte, 4), 2)
+		}(i)
+	}
+	select {
+	case <-r.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("block never fetched")
+	}
+	// Give the other readers time to wait for the fetch in progress.
+	time.Sleep(50 * time.Millisecond)
+	close(r.release)
+	wg.Wait()
+	return errs
+}
+
+func TestBlockConcurrentFetch(t *testing.T) {
+	r := &fakeReader{data: testData(20), started: make(chan struct{}, 10), release: make(chan struct{})}
+	b := NewBlockReader(r, WithBlockSize(10))
+	for i, err := range readConcurrently(t, b, r, 10) {
+		if err != nil {
+			t.Errorf("reader %d: %v", i, err)
+		}
+	}
+	if reads := r.takeReads(); len(reads) != 1 {
+		t.Errorf("%d fetches of the same block, want 1", len(reads))
+	}
+}
+
+func TestBlockFetchError(t *testing.T) {
+	errFetch := errors.New("connection reset")
+	r := &fakeReader{
+		data:    testData(20),
+		started: make(chan struct{}, 10),
+		release: make(chan struct{}),
+		errs:    []error{errFetch},
+	}
+	b := NewBlockReader(r, WithBlockSize(10))
+	for i, err := range readConcurrently(t, b, r, 10) {
+		if !errors.Is(err, errFetch) {
+			t.Errorf("reader %d: error = %v, want %v", i, err, errFetch)
+		}
+	}
+	if reads := r.takeReads(); len(reads) != 1 {
+		t.Errorf("%d fetches of the same block, want 1", len(reads))
+	}
+
+	// The error isn't cached: the block is fetched again.
+	p := make([]byte, 4)
+	if n, err := b.ReadAt(p, 2); err != nil || string(p[:n]) != "cdef" {
+		t.Errorf("ReadAt() after a failed fetch = %q, %v, want %q", p[:n], err, "cdef")
+	}
+	if reads := r.takeReads(); len(reads) != 1 {
+		t.Errorf("%d fetches after a failed fetch, want 1", len(reads))
+	}
+}
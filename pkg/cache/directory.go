@@ -0,0 +1,203 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alec-rabold/zipspy/pkg/zipspy"
+)
+
+var (
+	_ zipspy.DirectoryCache = (*DirectoryCache)(nil)
+	_ zipspy.IndexCache     = (*DirectoryCache)(nil)
+)
+
+// DirectoryCache implements the zipspy.DirectoryCache and zipspy.IndexCache interfaces
+// by storing central directories on disk, one file per location, and file indexes
+// alongside them, one file per location and file name.
+type DirectoryCache struct {
+	dir string
+}
+
+// directoryEntry is the on-disk form of a cached directory.
+type directoryEntry struct {
+	Location  string
+	Version   string
+	Directory []byte
+}
+
+// indexEntry is the on-disk form of a cached file index.
+type indexEntry struct {
+	Location string
+	Version  string
+	Name     string
+	Index    []byte
+}
+
+// NewDirectoryCache creates a directory cache rooted at dir.
+func NewDirectoryCache(dir string) *DirectoryCache {
+	return &DirectoryCache{dir: dir}
+}
+
+// DefaultDir returns the default cache location, "zipspy" under the user's cache directory
+// (e.g. $XDG_CACHE_HOME/zipspy on Linux).
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find user cache directory: %w", err)
+	}
+	return filepath.Join(dir, "zipspy"), nil
+}
+
+// Load returns the directory stored for the location if it was stored with the same version.
+func (c *DirectoryCache) Load(location, version string) ([]byte, bool) {
+	location = cacheKey(location)
+	var entry directoryEntry
+	if !c.read(c.path(location), &entry) {
+		return nil, false
+	}
+	if entry.Location != location || entry.Version != version {
+		return nil, false
+	}
+	return entry.Directory, true
+}
+
+// Store saves the directory for the location, replacing any previous version.
+func (c *DirectoryCache) Store(location, version string, directory []byte) error {
+	location = cacheKey(location)
+	entry := directoryEntry{
+		Location:  location,
+		Version:   version,
+		Directory: directory,
+	}
+	return c.write(c.path(location), entry)
+}
+
+// LoadIndex returns the index of a file in the archive at location,
+// if it was stored for the same version of the archive.
+func (c *DirectoryCache) LoadIndex(location, version, name string) ([]byte, bool) {
+	location = cacheKey(location)
+	var entry indexEntry
+	if !c.read(c.indexPath(location, name), &entry) {
+		return nil, false
+	}
+	if entry.Location != location || entry.Version != version || entry.Name != name {
+		return nil, false
+	}
+	return entry.Index, true
+}
+
+// StoreIndex saves the index of a file in the archive at location, replacing any previous version.
+func (c *DirectoryCache) StoreIndex(location, version, name string, index []byte) error {
+	location = cacheKey(location)
+	entry := indexEntry{
+		Location: location,
+		Version:  version,
+		Name:     name,
+		Index:    index,
+	}
+	return c.write(c.indexPath(location, name), entry)
+}
+
+// read decodes the cache file at path into entry.
+func (c *DirectoryCache) read(path string, entry interface{}) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	return gob.NewDecoder(f).Decode(entry) == nil
+}
+
+// write atomically replaces the cache file at path with entry.
+func (c *DirectoryCache) write(path string, entry interface{}) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory (path: %s): %w", dir, err)
+	}
+	f, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if err := gob.NewEncoder(f).Encode(entry); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	// Rename so concurrent readers never observe a partially written file.
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	return nil
+}
+
+func (c *DirectoryCache) path(location string) string {
+	sum := sha256.Sum256([]byte(location))
+	return filepath.Join(c.dir, "directories", hex.EncodeToString(sum[:]))
+}
+
+func (c *DirectoryCache) indexPath(location, name string) string {
+	sum := sha256.Sum256([]byte(location + "\x00" + name))
+	return filepath.Join(c.dir, "indexes", hex.EncodeToString(sum[:]))
+}
+
+// credentialParams are query parameters holding credentials rather than identifying the archive:
+// Azure shared access signatures, and the signatures of CloudFront signed URLs.
+// Parameters starting with "x-amz-" or "x-goog-" (S3 and GCS presigned URLs) are credentials too.
+var credentialParams = []string{
+	"sig", "sv", "ss", "srt", "sp", "se", "st", "spr", "sr", "si", "sdd",
+	"skoid", "sktid", "skt", "ske", "sks", "skv", "saoid", "suoid", "scid",
+	"signature", "policy", "key-pair-id", "expires",
+}
+
+// cacheKey returns the location without the credentials it may hold, i.e. a password in its
+// user info or a signature in its query, so that they aren't written to disk and a renewed
+// signature still finds the cached data. Entries are validated against the archive's version anyway.
+func cacheKey(location string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return location
+	}
+	changed := false
+	if _, ok := u.User.Password(); ok {
+		u.User = url.User(u.User.Username())
+		changed = true
+	}
+	if u.RawQuery != "" {
+		var kept []string
+		for _, param := range strings.Split(u.RawQuery, "&") {
+			name, _ := url.QueryUnescape(strings.SplitN(param, "=", 2)[0])
+			if isCredentialParam(strings.ToLower(name)) {
+				changed = true
+				continue
+			}
+			kept = append(kept, param)
+		}
+		u.RawQuery = strings.Join(kept, "&")
+	}
+	if !changed {
+		return location
+	}
+	return u.String()
+}
+
+func isCredentialParam(name string) bool {
+	if strings.HasPrefix(name, "x-amz-") || strings.HasPrefix(name, "x-goog-") {
+		return true
+	}
+	for _, p := range credentialParams {
+		if name == p {
+			return true
+		}
+	}
+	return false
+}
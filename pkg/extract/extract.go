@@ -0,0 +1,267 @@
+package extract
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alec-rabold/zipspy/pkg/reader"
+)
+
+// maxSymlinkTarget bounds the size of a symlink entry's contents (its target path).
+const maxSymlinkTarget = 4096
+
+// Extractor writes zip entries to a directory tree, recreating the archive's paths,
+// permissions and modification times. It is safe for concurrent use.
+//
+// Entry names are sanitized so that nothing is written outside of the destination:
+// absolute paths, drive letters, ".." traversal, symlinks pointing outside of the
+// destination and paths through symlinks are rejected with an *UnsafePathError,
+// unless the extractor was created with WithUnsafePaths. Symlinks are only created by
+// Finish, once no other entry is being written, so that concurrent extractions never
+// write through them and chains of symlinks can be checked against the final tree.
+type Extractor struct {
+	dest        string
+	unsafePaths bool
+
+	mu    sync.Mutex
+	dirs  map[string]dirAttrs // directory entries whose attributes are applied by Finish
+	links []symlink           // symlink entries created by Finish
+}
+
+// symlink is a symlink entry whose creation is deferred until Finish.
+type symlink struct {
+	name   string // name of the entry in the archive
+	rel    string // sanitized, slash-separated path beneath the destination
+	target string // path of the symlink on disk
+	link   string // contents of the symlink
+}
+
+type dirAttrs struct {
+	perm     fs.FileMode
+	modified time.Time
+}
+
+// Option configures an Extractor.
+type Option func(*Extractor)
+
+// WithUnsafePaths disables path sanitization, writing entries wherever their names point.
+// It should only be used for trusted archives.
+func WithUnsafePaths() Option {
+	return func(e *Extractor) {
+		e.unsafePaths = true
+	}
+}
+
+// NewExtractor creates an extractor writing to the dest directory.
+func NewExtractor(dest string, opts ...Option) *Extractor {
+	e := &Extractor{
+		dest: dest,
+		dirs: make(map[string]dirAttrs),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Extract writes a single entry beneath the destination directory.
+// Directory entries are created, symlink entries become symlinks to the entry's contents,
+// and all other entries are written as regular files.
+func (e *Extractor) Extract(f *reader.File) error {
+	rel := f.Name
+	if !e.unsafePaths {
+		var err error
+		if rel, err = sanitizeName(f.Name); err != nil {
+			return err
+		}
+		if err := checkNoSymlinkParents(f.Name, e.dest, rel); err != nil {
+			return err
+		}
+	}
+	target := filepath.Join(e.dest, filepath.FromSlash(rel))
+	mode := f.Mode()
+	switch {
+	case mode.IsDir() || strings.HasSuffix(f.Name, "/"):
+		return e.extractDir(f, target)
+	case rel == ".":
+		return &UnsafePathError{Name: f.Name, Reason: "file name refers to the destination directory"}
+	case mode&fs.ModeSymlink != 0:
+		return e.extractSymlink(f, rel, target)
+	default:
+		return e.extractFile(f, target)
+	}
+}
+
+// Finish creates the symlink entries and applies the permissions and modification times
+// of directory entries. It must be called once all entries have been extracted, since
+// creating files inside a directory updates its modification time (and may not be permitted
+// by its mode). It returns an error for each symlink that could not be created safely,
+// followed by any failure to apply the attributes of directories.
+func (e *Extractor) Finish() []error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	errs := e.createSymlinks()
+	// Apply the deepest directories first so parents are updated last.
+	dirs := make([]string, 0, len(e.dirs))
+	for dir := range e.dirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, dir := range dirs {
+		attrs := e.dirs[dir]
+		if err := os.Chmod(dir, attrs.perm); err != nil {
+			return append(errs, fmt.Errorf("failed to set permissions (path: %s): %w", dir, err))
+		}
+		if attrs.modified.IsZero() {
+			continue
+		}
+		if err := os.Chtimes(dir, attrs.modified, attrs.modified); err != nil {
+			return append(errs, fmt.Errorf("failed to set modification time (path: %s): %w", dir, err))
+		}
+	}
+	return errs
+}
+
+// createSymlinks creates the deferred symlinks, parents first, then removes those that
+// resolve outside of the destination once all of them exist. Checking each symlink on its own
+// isn't enough: "l1 -> ." and "sub/l2 -> ../l1/.." are both harmless as text, but together
+// "sub/l2" points to the parent of the destination.
+func (e *Extractor) createSymlinks() []error {
+	sort.Slice(e.links, func(i, j int) bool {
+		return e.links[i].rel < e.links[j].rel
+	})
+	var errs []error
+	var created []symlink
+	for _, l := range e.links {
+		// Parent symlinks were created by earlier iterations.
+		if err := checkNoSymlinkParents(l.name, e.dest, l.rel); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := createSymlink(l.link, l.target); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		created = append(created, l)
+	}
+	e.links = nil
+
+	// Removing a symlink can only make the others resolve to fewer places, but repeat until none escape.
+	for removed := true; removed; {
+		removed = false
+		remaining := created[:0]
+		for _, l := range created {
+			err := resolveInside(e.dest, l.rel)
+			if err == nil {
+				remaining = append(remaining, l)
+				continue
+			}
+			if rmErr := os.Remove(l.target); rmErr != nil {
+				errs = append(errs, fmt.Errorf("failed to remove unsafe symlink (path: %s): %w", l.target, rmErr))
+			}
+			errs = append(errs, &UnsafePathError{Name: l.name, Reason: fmt.Sprintf("symlink target %q %v", l.link, err)})
+			removed = true
+		}
+		created = remaining
+	}
+	return errs
+}
+
+func (e *Extractor) extractDir(f *reader.File, target string) error {
+	perm := f.Mode().Perm()
+	if perm == 0 {
+		perm = 0755
+	}
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return fmt.Errorf("failed to create directory (path: %s): %w", target, err)
+	}
+	e.mu.Lock()
+	e.dirs[target] = dirAttrs{perm: perm, modified: f.Modified}
+	e.mu.Unlock()
+	return nil
+}
+
+func (e *Extractor) extractSymlink(f *reader.File, rel, target string) error {
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open file (name: %s): %w", f.Name, err)
+	}
+	defer rc.Close()
+	link, err := io.ReadAll(io.LimitReader(rc, maxSymlinkTarget+1))
+	if err != nil {
+		return fmt.Errorf("failed to read symlink (name: %s): %w", f.Name, err)
+	}
+	if len(link) > maxSymlinkTarget {
+		return fmt.Errorf("symlink target too long (name: %s)", f.Name)
+	}
+	if e.unsafePaths {
+		return createSymlink(string(link), target)
+	}
+	if err := checkSymlinkTarget(f.Name, rel, string(link)); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	e.links = append(e.links, symlink{name: f.Name, rel: rel, target: target, link: string(link)})
+	e.mu.Unlock()
+	return nil
+}
+
+func createSymlink(link, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create directory (path: %s): %w", filepath.Dir(target), err)
+	}
+	// Replace whatever is already there, like unzip -o.
+	if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to replace file (path: %s): %w", target, err)
+	}
+	if err := os.Symlink(link, target); err != nil {
+		return fmt.Errorf("failed to create symlink (path: %s): %w", target, err)
+	}
+	return nil
+}
+
+func (e *Extractor) extractFile(f *reader.File, target string) error {
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open file (name: %s): %w", f.Name, err)
+	}
+	defer rc.Close()
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create directory (path: %s): %w", filepath.Dir(target), err)
+	}
+	// Remove any existing symlink so we never write through it.
+	if fi, err := os.Lstat(target); err == nil && fi.Mode()&fs.ModeSymlink != 0 {
+		if err := os.Remove(target); err != nil {
+			return fmt.Errorf("failed to replace file (path: %s): %w", target, err)
+		}
+	}
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create file (path: %s): %w", target, err)
+	}
+	if _, err := io.Copy(out, rc); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to extract file (name: %s): %w", f.Name, err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write file (path: %s): %w", target, err)
+	}
+	if perm := f.Mode().Perm(); perm != 0 {
+		if err := os.Chmod(target, perm); err != nil {
+			return fmt.Errorf("failed to set permissions (path: %s): %w", target, err)
+		}
+	}
+	if !f.Modified.IsZero() {
+		if err := os.Chtimes(target, f.Modified, f.Modified); err != nil {
+			return fmt.Errorf("failed to set modification time (path: %s): %w", target, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alec-rabold/zipspy/pkg/reader"
+)
+
+type entry struct {
+	name string
+	link string // contents of a symlink entry, or empty for a regular file
+}
+
+// newArchive builds an archive of the given entries.
+func newArchive(t *testing.T, entries []entry) *reader.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		fh := &zip.FileHeader{Name: e.name, Method: zip.Store}
+		contents := "contents of " + e.name
+		if e.link != "" {
+			fh.SetMode(fs.ModeSymlink | 0777)
+			contents = e.link
+		}
+		w, err := zw.CreateHeader(fh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := reader.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zr
+}
+
+// extractAll extracts every entry concurrently, like "extract --workers", and returns the errors.
+func extractAll(e *Extractor, zr *reader.Reader) []error {
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	for _, f := range zr.File {
+		wg.Add(1)
+		go func(f *reader.File) {
+			defer wg.Done()
+			if err := e.Extract(f); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(f)
+	}
+	wg.Wait()
+	return append(errs, e.Finish()...)
+}
+
+func TestExtractSymlinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []entry
+		unsafe  []string // entries expected to be rejected
+		links   []string // symlinks expected to be created
+		dirs    []string // real directories expected to be created
+	}{
+		{
+			name:    "inside",
+			entries: []entry{{name: "dir/file.txt"}, {name: "link", link: "dir/file.txt"}, {name: "dir/up", link: "../dir"}},
+			links:   []string{"link", "dir/up"},
+		},
+		{
+			name:    "outside",
+			entries: []entry{{name: "link", link: "../outside"}, {name: "abs", link: "/etc"}},
+			unsafe:  []string{"link", "abs"},
+		},
+		{
+			name:    "chain",
+			entries: []entry{{name: "l1", link: "."}, {name: "sub/l2", link: "../l1/.."}},
+			unsafe:  []string{"sub/l2"},
+			links:   []string{"l1"},
+		},
+		{
+			name:    "chain through a later symlink",
+			entries: []entry{{name: "a", link: "x/.."}, {name: "x", link: "."}},
+			unsafe:  []string{"a"},
+			links:   []string{"x"},
+		},
+		{
+			name: "file through symlink",
+			// The file is written first, so the symlink can't replace its directory.
+			entries: []entry{{name: "l", link: "."}, {name: "l/l/file.txt"}},
+			dirs:    []string{"l", "l/l"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			dest := filepath.Join(root, "dest")
+			if err := os.Mkdir(dest, 0755); err != nil {
+				t.Fatal(err)
+			}
+			errs := extractAll(NewExtractor(dest), newArchive(t, tt.entries))
+
+			rejected := map[string]bool{}
+			for _, err := range errs {
+				var unsafeErr *UnsafePathError
+				if errors.As(err, &unsafeErr) {
+					rejected[unsafeErr.Name] = true
+				}
+			}
+			for _, name := range tt.unsafe {
+				if !rejected[name] {
+					t.Errorf("entry %s was not rejected (errors: %v)", name, errs)
+				}
+				if _, err := os.Lstat(filepath.Join(dest, name)); err == nil {
+					t.Errorf("unsafe entry %s was created", name)
+				}
+			}
+			for _, name := range tt.links {
+				if fi, err := os.Lstat(filepath.Join(dest, name)); err != nil || fi.Mode()&fs.ModeSymlink == 0 {
+					t.Errorf("symlink %s was not created (errors: %v)", name, errs)
+				}
+			}
+			for _, name := range tt.dirs {
+				if fi, err := os.Lstat(filepath.Join(dest, name)); err != nil || !fi.IsDir() {
+					t.Errorf("directory %s was not created (errors: %v)", name, errs)
+				}
+			}
+			// Nothing may be written next to the destination.
+			siblings, err := os.ReadDir(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(siblings) != 1 {
+				t.Errorf("files written outside of the destination: %v", siblings)
+			}
+		})
+	}
+}
+
+func TestExtractAttributes(t *testing.T) {
+	modified := time.Date(2021, 6, 1, 12, 30, 0, 0, time.UTC)
+	files := []struct {
+		name     string
+		mode     fs.FileMode
+		modified time.Time
+	}{
+		{name: "dir/", mode: fs.ModeDir | 0750, modified: modified},
+		{name: "dir/file.txt", mode: 0600, modified: modified.Add(time.Hour)},
+		{name: "empty/", mode: fs.ModeDir | 0700, modified: modified.Add(2 * time.Hour)},
+		{name: "script.sh", mode: 0755, modified: modified.Add(3 * time.Hour)},
+		{name: "existing.txt", mode: 0640, modified: modified.Add(4 * time.Hour)},
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		fh := &zip.FileHeader{Name: f.name, Method: zip.Store, Modified: f.modified}
+		fh.SetMode(f.mode)
+		w, err := zw.CreateHeader(fh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !f.mode.IsDir() {
+			w.Write([]byte("contents of " + f.name))
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := reader.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An existing file is replaced, not appended to or partly overwritten.
+	dest := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dest, "existing.txt"), bytes.Repeat([]byte("old "), 100), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if errs := extractAll(NewExtractor(dest), zr); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+
+	for _, f := range files {
+		path := filepath.Join(dest, f.name)
+		fi, err := os.Lstat(path)
+		if err != nil {
+			t.Errorf("%s: %v", f.name, err)
+			continue
+		}
+		if fi.Mode() != f.mode {
+			t.Errorf("%s: mode = %v, want %v", f.name, fi.Mode(), f.mode)
+		}
+		// Directories keep their time even though files were created inside them.
+		if !fi.ModTime().Equal(f.modified) {
+			t.Errorf("%s: modification time = %v, want %v", f.name, fi.ModTime(), f.modified)
+		}
+		if f.mode.IsDir() {
+			continue
+		}
+		if b, err := os.ReadFile(path); err != nil || string(b) != "contents of "+f.name {
+			t.Errorf("%s = %q, %v, want %q", f.name, b, err, "contents of "+f.name)
+		}
+	}
+}
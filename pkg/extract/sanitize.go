@@ -0,0 +1,134 @@
+package extract
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// UnsafePathError is returned when an entry would be written outside of the destination directory.
+type UnsafePathError struct {
+	// Name is the name of the entry as stored in the archive.
+	Name string
+	// Reason describes why the path was rejected.
+	Reason string
+}
+
+func (e *UnsafePathError) Error() string {
+	return fmt.Sprintf("unsafe path in archive (name: %s): %s", e.Name, e.Reason)
+}
+
+// sanitizeName converts an entry name into a clean, relative, slash-separated path.
+// Backslashes are rewritten as separators and harmless ".." elements are resolved,
+// while names that are absolute, start with a drive letter or escape the destination are rejected.
+func sanitizeName(name string) (string, error) {
+	if strings.IndexByte(name, 0) >= 0 {
+		return "", &UnsafePathError{Name: name, Reason: "name contains a NUL byte"}
+	}
+	p := strings.ReplaceAll(name, `\`, "/")
+	if len(p) >= 2 && p[1] == ':' && isLetter(p[0]) {
+		return "", &UnsafePathError{Name: name, Reason: "name starts with a drive letter"}
+	}
+	if strings.HasPrefix(p, "/") {
+		return "", &UnsafePathError{Name: name, Reason: "name is an absolute path"}
+	}
+	p = path.Clean(p)
+	if escapes(p) {
+		return "", &UnsafePathError{Name: name, Reason: "name traverses outside of the destination"}
+	}
+	return p, nil
+}
+
+// checkSymlinkTarget verifies that a symlink at the relative, slash-separated path p
+// pointing to target resolves inside of the destination.
+func checkSymlinkTarget(name, p, target string) error {
+	t := strings.ReplaceAll(target, `\`, "/")
+	if strings.HasPrefix(t, "/") || filepath.IsAbs(target) || len(t) >= 2 && t[1] == ':' && isLetter(t[0]) {
+		return &UnsafePathError{Name: name, Reason: fmt.Sprintf("symlink target %q is an absolute path", target)}
+	}
+	if escapes(path.Join(path.Dir(p), t)) {
+		return &UnsafePathError{Name: name, Reason: fmt.Sprintf("symlink target %q points outside of the destination", target)}
+	}
+	return nil
+}
+
+// maxSymlinkHops bounds the number of symlinks followed by resolveInside, like the operating system's ELOOP limit.
+const maxSymlinkHops = 40
+
+// resolveInside follows the relative, slash-separated path p beneath dest through the symlinks
+// that exist on disk, like the operating system would, and reports an error if it leads outside of dest.
+// Elements that don't exist are resolved lexically.
+func resolveInside(dest, p string) error {
+	var resolved []string // elements of the path resolved so far, beneath dest
+	pending := strings.Split(p, "/")
+	hops := 0
+	for len(pending) > 0 {
+		elem := pending[0]
+		pending = pending[1:]
+		switch elem {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) == 0 {
+				return errors.New("resolves outside of the destination")
+			}
+			resolved = resolved[:len(resolved)-1]
+			continue
+		}
+		resolved = append(resolved, elem)
+		full := filepath.Join(dest, filepath.FromSlash(strings.Join(resolved, "/")))
+		fi, err := os.Lstat(full)
+		if err != nil || fi.Mode()&fs.ModeSymlink == 0 {
+			continue
+		}
+		if hops++; hops > maxSymlinkHops {
+			return errors.New("resolves through too many levels of symlinks")
+		}
+		link, err := os.Readlink(full)
+		if err != nil {
+			return fmt.Errorf("can't be resolved: %w", err)
+		}
+		if filepath.IsAbs(link) || strings.HasPrefix(filepath.ToSlash(link), "/") {
+			return fmt.Errorf("resolves through the absolute symlink %s", full)
+		}
+		// Continue from the symlink's directory with its target.
+		resolved = resolved[:len(resolved)-1]
+		pending = append(strings.Split(filepath.ToSlash(link), "/"), pending...)
+	}
+	return nil
+}
+
+// checkNoSymlinkParents verifies that none of the directories between dest and the
+// relative, slash-separated path p are symlinks, so that entries are never written
+// through a symlink created by an earlier entry (or already present in dest).
+func checkNoSymlinkParents(name, dest, p string) error {
+	dir := dest
+	elems := strings.Split(p, "/")
+	for _, elem := range elems[:len(elems)-1] {
+		dir = filepath.Join(dir, elem)
+		fi, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to stat directory (path: %s): %w", dir, err)
+		}
+		if fi.Mode()&fs.ModeSymlink != 0 {
+			return &UnsafePathError{Name: name, Reason: fmt.Sprintf("path traverses the symlink %s", dir)}
+		}
+	}
+	return nil
+}
+
+// escapes reports whether the cleaned, slash-separated path p is outside of its root.
+func escapes(p string) bool {
+	return p == ".." || strings.HasPrefix(p, "../") || strings.HasPrefix(p, "/")
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
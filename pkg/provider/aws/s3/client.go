@@ -1,25 +1,40 @@
 package s3
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/alec-rabold/zipspy/pkg/zipspy"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 )
 
-// TODO: add a cache plugin?
-
 var _ zipspy.Reader = (*Client)(nil)
+var _ zipspy.Versioner = (*Client)(nil)
+
+// ErrObjectChanged is returned when the object is overwritten while it is being read.
+var ErrObjectChanged = errors.New("object changed while reading")
 
-// Client implements the <<SOMETHING>> interface.
+// Client implements the zipspy.Reader interface over S3 range requests.
+// Every range request is made conditional on the ETag returned by the first HEAD request,
+// so that an archive overwritten mid-read fails with ErrObjectChanged rather than returning corrupt data.
 type Client struct {
 	bucket string
 	key    string
 	s3     S3API
+	opts   options
+
+	headOnce   sync.Once
+	headOutput *s3.HeadObjectOutput
+	headErr    error
 }
 
 // S3API contains the S3 API endpoints we care about in this package.
@@ -28,45 +43,246 @@ type S3API interface {
 	HeadObject(input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error)
 }
 
-// NewClient creates a new AWS S3 file reader.
-func NewClient(location string) (zipspy.Reader, error) {
-	sess := session.Must(session.NewSessionWithOptions(session.Options{
-		SharedConfigState: session.SharedConfigEnable,
-	}))
-	endpoint, err := url.Parse(location)
+// Option configures the S3 session of a client.
+type Option func(*options)
+
+type options struct {
+	endpoint      string
+	region        string
+	profile       string
+	pathStyle     bool
+	versionID     string
+	requesterPays bool
+	sseKey        string
+}
+
+// WithEndpoint sets a custom endpoint URL, for S3-compatible servers such as MinIO or LocalStack
+// (e.g. "http://localhost:9000").
+func WithEndpoint(endpoint string) Option {
+	return func(o *options) {
+		o.endpoint = endpoint
+	}
+}
+
+// WithRegion sets the region, instead of the one from the environment or shared config.
+func WithRegion(region string) Option {
+	return func(o *options) {
+		o.region = region
+	}
+}
+
+// WithProfile sets the shared config profile used for credentials and settings.
+func WithProfile(profile string) Option {
+	return func(o *options) {
+		o.profile = profile
+	}
+}
+
+// WithPathStyle addresses buckets in the path of requests ("endpoint/bucket/key")
+// rather than as a subdomain ("bucket.endpoint/key"), as most S3-compatible servers require.
+func WithPathStyle(pathStyle bool) Option {
+	return func(o *options) {
+		o.pathStyle = pathStyle
+	}
+}
+
+// WithVersionID reads the given version of the object, rather than the latest one.
+func WithVersionID(versionID string) Option {
+	return func(o *options) {
+		o.versionID = versionID
+	}
+}
+
+// WithRequesterPays acknowledges that requests to a requester-pays bucket are charged to the requester.
+func WithRequesterPays(requesterPays bool) Option {
+	return func(o *options) {
+		o.requesterPays = requesterPays
+	}
+}
+
+// WithSSECustomerKey sets the 256-bit key of an object encrypted with a customer-provided key (SSE-C).
+// The key is given as is, not base64 encoded.
+func WithSSECustomerKey(key string) Option {
+	return func(o *options) {
+		o.sseKey = key
+	}
+}
+
+// NewCreator returns a provider constructor for "s3://" locations using the given options.
+// The provider registry strips the protocol from the location, so it is added back here.
+func NewCreator(opts ...Option) func(location string) (zipspy.Reader, error) {
+	return func(location string) (zipspy.Reader, error) {
+		return NewClient("s3://"+location, opts...)
+	}
+}
+
+// NewClient creates a new AWS S3 file reader for a location of the form "s3://bucket/key".
+// The location may set the endpoint, region, profile, path_style, version_id and requester_pays
+// options as query parameters, e.g. "s3://bucket/key?endpoint=http://localhost:9000&path_style=true",
+// which take precedence over opts.
+// Since the location is a URI, "?", "#" and "%" characters in the key must be percent-encoded
+// (as "%3F", "%23" and "%25").
+func NewClient(location string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(location)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse S3 URI: %w", err)
+		return nil, fmt.Errorf("failed to parse S3 URI (keys containing %%, ? or # must escape them as %%25, %%3F and %%23): %w", err)
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+	if u.Scheme != "s3" || u.Host == "" || key == "" {
+		return nil, fmt.Errorf("invalid S3 URI, expected s3://<bucket>/<key> (location: %s)", location)
+	}
+	if u.Fragment != "" || strings.HasSuffix(location, "#") {
+		return nil, fmt.Errorf("invalid S3 URI, keys containing # must escape it as %%23 (location: %s)", location)
+	}
+	if u.ForceQuery {
+		return nil, fmt.Errorf("invalid S3 URI, keys containing ? must escape it as %%3F (location: %s)", location)
+	}
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if err := o.parseQuery(u.Query()); err != nil {
+		return nil, fmt.Errorf("invalid S3 URI (location: %s): %w", location, err)
+	}
+	if o.sseKey != "" && len(o.sseKey) != 32 {
+		return nil, fmt.Errorf("SSE-C key must be 256 bits long (length: %d bytes)", len(o.sseKey))
+	}
+	sess, err := o.session()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %w", err)
 	}
 	return &Client{
-		bucket: endpoint.Host,
-		key:    endpoint.Path,
+		bucket: u.Host,
+		key:    key,
 		s3:     s3.New(sess),
+		opts:   o,
 	}, nil
 }
 
+// parseQuery overrides the options with the query parameters of a location.
+func (o *options) parseQuery(query url.Values) error {
+	for name, values := range query {
+		value := values[len(values)-1]
+		switch name {
+		case "endpoint":
+			o.endpoint = value
+		case "region":
+			o.region = value
+		case "profile":
+			o.profile = value
+		case "path_style":
+			pathStyle, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid value for path_style %q: %w", value, err)
+			}
+			o.pathStyle = pathStyle
+		case "version_id":
+			o.versionID = value
+		case "requester_pays":
+			requesterPays, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid value for requester_pays %q: %w", value, err)
+			}
+			o.requesterPays = requesterPays
+		default:
+			return fmt.Errorf("unknown query parameter %q (supported: endpoint, region, profile, path_style, version_id, requester_pays; keys containing ? must escape it as %%3F)", name)
+		}
+	}
+	return nil
+}
+
+func (o *options) session() (*session.Session, error) {
+	config := aws.Config{S3ForcePathStyle: aws.Bool(o.pathStyle)}
+	if o.endpoint != "" {
+		config.Endpoint = aws.String(o.endpoint)
+	}
+	if o.region != "" {
+		config.Region = aws.String(o.region)
+	}
+	return session.NewSessionWithOptions(session.Options{
+		Config:            config,
+		Profile:           o.profile,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+}
+
 // Size returns the size of the object.
 func (c *Client) Size() (int64, error) {
-	output, err := c.s3.HeadObject(&s3.HeadObjectInput{
-		Bucket: aws.String(c.bucket),
-		Key:    aws.String(c.key),
-	})
+	output, err := c.head()
 	if err != nil {
-		return 0, fmt.Errorf("failed getting head object (bucket: %s) (key: %s): %w", c.bucket, c.key, err)
+		return 0, err
 	}
-	return *output.ContentLength, nil
+	return aws.Int64Value(output.ContentLength), nil
+}
+
+// Version returns the ETag of the object.
+func (c *Client) Version() (string, error) {
+	output, err := c.head()
+	if err != nil {
+		return "", err
+	}
+	if output.ETag == nil {
+		return "", fmt.Errorf("object has no ETag (bucket: %s) (key: %s)", c.bucket, c.key)
+	}
+	return *output.ETag, nil
+}
+
+// head fetches the object's metadata once and reuses it for subsequent calls.
+func (c *Client) head() (*s3.HeadObjectOutput, error) {
+	c.headOnce.Do(func() {
+		input := &s3.HeadObjectInput{
+			Bucket: aws.String(c.bucket),
+			Key:    aws.String(c.key),
+		}
+		if c.opts.versionID != "" {
+			input.VersionId = aws.String(c.opts.versionID)
+		}
+		if c.opts.requesterPays {
+			input.RequestPayer = aws.String(s3.RequestPayerRequester)
+		}
+		if c.opts.sseKey != "" {
+			input.SSECustomerAlgorithm = aws.String(s3.ServerSideEncryptionAes256)
+			input.SSECustomerKey = aws.String(c.opts.sseKey)
+		}
+		c.headOutput, c.headErr = c.s3.HeadObject(input)
+		if c.headErr != nil {
+			c.headErr = fmt.Errorf("failed getting head object (bucket: %s) (key: %s): %w", c.bucket, c.key, c.headErr)
+		}
+	})
+	return c.headOutput, c.headErr
 }
 
 // ReadAt implements the io.ReaderAt interface by downloading a byte range of the object.
 func (c *Client) ReadAt(p []byte, off int64) (n int, err error) {
+	head, err := c.head()
+	if err != nil {
+		return 0, err
+	}
 	byteRange := fmt.Sprintf("bytes=%v-%v", off, off+int64(len(p)-1))
-	output, err := c.s3.GetObject(&s3.GetObjectInput{
-		Bucket: aws.String(c.bucket),
-		Key:    aws.String(c.key),
-		Range:  aws.String(byteRange),
-	})
+	input := &s3.GetObjectInput{
+		Bucket:  aws.String(c.bucket),
+		Key:     aws.String(c.key),
+		Range:   aws.String(byteRange),
+		IfMatch: head.ETag,
+	}
+	if c.opts.versionID != "" {
+		input.VersionId = aws.String(c.opts.versionID)
+	}
+	if c.opts.requesterPays {
+		input.RequestPayer = aws.String(s3.RequestPayerRequester)
+	}
+	if c.opts.sseKey != "" {
+		input.SSECustomerAlgorithm = aws.String(s3.ServerSideEncryptionAes256)
+		input.SSECustomerKey = aws.String(c.opts.sseKey)
+	}
+	output, err := c.s3.GetObject(input)
+	if aerr, ok := err.(awserr.RequestFailure); ok && aerr.StatusCode() == http.StatusPreconditionFailed {
+		err = fmt.Errorf("%w (etag: %s): %v", ErrObjectChanged, aws.StringValue(head.ETag), err)
+	}
 	if err != nil {
 		return 0, fmt.Errorf("failed getting object (bucket: %s) (key: %s) (range: %s): %w", c.bucket, c.key, byteRange, err)
 	}
+	defer output.Body.Close()
 	body, err := ioutil.ReadAll(output.Body)
 	if err != nil {
 		return 0, fmt.Errorf("failed to read response body: %w", err)
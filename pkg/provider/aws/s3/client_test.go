@@ -0,0 +1,180 @@
+package s3
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alec-rabold/zipspy/pkg/zipspy"
+)
+
+// fakeS3 is an S3-compatible server for path-style requests, holding objects by "/bucket/key".
+type fakeS3 struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	etag     string
+	requests []*http.Request
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r)
+	data, ok := s.objects[r.URL.Path]
+	etag := s.etag
+	s.mu.Unlock()
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	// ServeContent answers HEAD, Range and If-Match requests.
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
+
+// newFakeS3 starts a TLS server, trusted through AWS_CA_BUNDLE, with static credentials in the environment.
+// TLS is needed since the SDK refuses to send SSE-C keys over plain HTTP.
+func newFakeS3(t *testing.T, objects map[string][]byte) (*fakeS3, string) {
+	t.Helper()
+	s := &fakeS3{objects: objects, etag: `"etag1"`}
+	srv := httptest.NewTLSServer(s)
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(bundle, cert, 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_CA_BUNDLE", bundle)
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	return s, srv.URL
+}
+
+// newArchive returns an archive holding a single file.
+func newArchive(t *testing.T, name, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(content))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadArchive(t *testing.T) {
+	s, endpoint := newFakeS3(t, map[string][]byte{
+		"/my-bucket/dir/what?.zip": newArchive(t, "notes.txt", "Notes from file."),
+	})
+	sseKey := strings.Repeat("k", 32)
+	c, err := NewClient("s3://my-bucket/dir/what%3F.zip?endpoint="+endpoint+"&region=us-east-1&path_style=true&version_id=v7&requester_pays=true",
+		WithSSECustomerKey(sseKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version, err := c.Version(); err != nil || version != `"etag1"` {
+		t.Errorf("Version() = %q, %v, want %q", version, err, `"etag1"`)
+	}
+
+	zc, err := zipspy.NewClient(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := zc.GetFiles([]string{"notes.txt"})
+	if len(files) != 1 {
+		t.Fatalf("GetFiles() returned %d files, want 1", len(files))
+	}
+	rc, err := files[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if b, err := io.ReadAll(rc); err != nil || string(b) != "Notes from file." {
+		t.Errorf("notes.txt = %q, %v, want %q", b, err, "Notes from file.")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) < 2 || s.requests[0].Method != http.MethodHead {
+		t.Fatalf("%d requests, want a HEAD followed by range requests", len(s.requests))
+	}
+	for _, r := range s.requests {
+		if r.Method == http.MethodGet && (r.Header.Get("Range") == "" || r.Header.Get("If-Match") != `"etag1"`) {
+			t.Errorf("GET with Range %q and If-Match %q, want a conditional range request", r.Header.Get("Range"), r.Header.Get("If-Match"))
+		}
+		headers := map[string]string{
+			"X-Amz-Request-Payer":                             "requester",
+			"X-Amz-Server-Side-Encryption-Customer-Algorithm": "AES256",
+		}
+		for name, want := range headers {
+			if got := r.Header.Get(name); got != want {
+				t.Errorf("%s request header %s = %q, want %q", r.Method, name, got, want)
+			}
+		}
+		if got := r.URL.Query().Get("versionId"); got != "v7" {
+			t.Errorf("%s request versionId = %q, want %q", r.Method, got, "v7")
+		}
+	}
+}
+
+func TestObjectChanged(t *testing.T) {
+	s, endpoint := newFakeS3(t, map[string][]byte{"/my-bucket/archive.zip": []byte("0123456789")})
+	c, err := NewClient("s3://my-bucket/archive.zip", WithEndpoint(endpoint), WithRegion("us-east-1"), WithPathStyle(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size, err := c.Size(); err != nil || size != 10 {
+		t.Fatalf("Size() = %d, %v, want 10", size, err)
+	}
+	p := make([]byte, 4)
+	if n, err := c.ReadAt(p, 2); err != nil || string(p[:n]) != "2345" {
+		t.Errorf("ReadAt() = %q, %v, want %q", p[:n], err, "2345")
+	}
+
+	s.mu.Lock()
+	s.etag = `"etag2"`
+	s.mu.Unlock()
+	if _, err := c.ReadAt(p, 2); !errors.Is(err, ErrObjectChanged) {
+		t.Errorf("ReadAt() of an overwritten object: error = %v, want %v", err, ErrObjectChanged)
+	}
+}
+
+func TestInvalidLocation(t *testing.T) {
+	for _, location := range []string{
+		"s3://my-bucket/what?.zip", // "?" starts the query
+		"s3://my-bucket/what?",
+		"s3://my-bucket/what#.zip",
+		"s3://my-bucket/100%.zip",
+		"s3://my-bucket/",
+		"gs://my-bucket/archive.zip",
+		"s3://my-bucket/archive.zip?path_style=maybe",
+	} {
+		if _, err := NewClient(location); err == nil {
+			t.Errorf("NewClient(%q): no error", location)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package blob
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alec-rabold/zipspy/pkg/zipspy"
+)
+
+var _ zipspy.Reader = (*Client)(nil)
+var _ zipspy.Versioner = (*Client)(nil)
+
+// apiVersion is the version of the Blob service REST API sent with every request.
+const apiVersion = "2020-10-02"
+
+// hostSuffix identifies the blob endpoints of storage accounts in the Azure public cloud.
+const hostSuffix = ".blob.core.windows.net"
+
+// ErrBlobChanged is returned when the blob is overwritten while it is being read.
+var ErrBlobChanged = errors.New("blob changed while reading")
+
+// Client implements the zipspy.Reader interface over ranged downloads of an Azure blob.
+// Every download is made conditional on the ETag returned by the first request,
+// so that an archive overwritten mid-read fails with ErrBlobChanged rather than returning corrupt data.
+type Client struct {
+	container string
+	blob      string
+	url       string // URL of the blob, without the SAS token
+	sasToken  string
+	sharedKey *sharedKey
+	http      *http.Client
+
+	propsOnce sync.Once
+	size      int64
+	etag      string
+	propsErr  error
+}
+
+// Option configures an Azure Blob client.
+type Option func(*options)
+
+type options struct {
+	account          string
+	accountKey       string
+	sasToken         string
+	connectionString string
+	endpoint         string
+	http             *http.Client
+}
+
+// WithAccount sets the storage account of "az://" locations.
+func WithAccount(account string) Option {
+	return func(o *options) {
+		o.account = account
+	}
+}
+
+// WithAccountKey authenticates requests with the base64-encoded key of the storage account (shared key).
+func WithAccountKey(key string) Option {
+	return func(o *options) {
+		o.accountKey = key
+	}
+}
+
+// WithSASToken authenticates requests with a shared access signature, which takes precedence over the account key.
+func WithSASToken(token string) Option {
+	return func(o *options) {
+		o.sasToken = strings.TrimPrefix(token, "?")
+	}
+}
+
+// WithConnectionString reads the account, key, SAS token and endpoint from a storage account connection string.
+// Settings given with other options take precedence over the connection string.
+func WithConnectionString(connectionString string) Option {
+	return func(o *options) {
+		o.connectionString = connectionString
+	}
+}
+
+// WithEndpoint sets the blob endpoint of "az://" locations, including the account for path-style
+// endpoints such as Azurite's ("http://127.0.0.1:10000/devstoreaccount1").
+func WithEndpoint(endpoint string) Option {
+	return func(o *options) {
+		o.endpoint = endpoint
+	}
+}
+
+// WithHTTPClient overrides the underlying HTTP client (e.g. for custom transports or tests).
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.http = client
+	}
+}
+
+// IsBlobURL reports whether the location is the URL of a blob in the Azure public cloud,
+// e.g. "https://myaccount.blob.core.windows.net/container/archive.zip".
+func IsBlobURL(location string) bool {
+	u, err := url.Parse(location)
+	return err == nil && u.Scheme == "https" && strings.HasSuffix(u.Hostname(), hostSuffix)
+}
+
+// NewCreator returns a provider constructor for "az://" locations using the given options.
+// The provider registry strips the protocol from the location, so it is added back here.
+func NewCreator(opts ...Option) func(location string) (zipspy.Reader, error) {
+	return func(location string) (zipspy.Reader, error) {
+		return NewClient("az://"+location, opts...)
+	}
+}
+
+// NewURLCreator returns a provider constructor for blob URLs (see IsBlobURL) using the given options.
+func NewURLCreator(opts ...Option) func(location string) (zipspy.Reader, error) {
+	return func(location string) (zipspy.Reader, error) {
+		return NewClient(location, opts...)
+	}
+}
+
+// NewClient creates a new Azure blob reader for a location of the form "az://container/blob",
+// in the account given by the options, or for the URL of a blob, e.g.
+// "https://myaccount.blob.core.windows.net/container/blob?<SAS token>".
+//
+// Requests are authenticated with a SAS token if one is given in the URL or the options,
+// otherwise with the account key if one is given, and are anonymous otherwise (for public containers).
+func NewClient(location string, opts ...Option) (*Client, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.connectionString != "" {
+		cs, err := parseConnectionString(o.connectionString)
+		if err != nil {
+			return nil, fmt.Errorf("invalid connection string: %w", err)
+		}
+		o.account = firstNonEmpty(o.account, cs.account)
+		o.accountKey = firstNonEmpty(o.accountKey, cs.key)
+		o.sasToken = firstNonEmpty(o.sasToken, strings.TrimPrefix(cs.sasToken, "?"))
+		o.endpoint = firstNonEmpty(o.endpoint, cs.endpoint)
+	}
+
+	var path string
+	if strings.HasPrefix(location, "az://") {
+		path = strings.TrimPrefix(location, "az://")
+		if o.endpoint == "" {
+			if o.account == "" {
+				return nil, fmt.Errorf("an account, endpoint or connection string is required for az:// locations (location: %s)", location)
+			}
+			o.endpoint = "https://" + o.account + hostSuffix
+		}
+	} else {
+		u, err := url.Parse(location)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, fmt.Errorf("invalid Azure blob location, expected az://<container>/<blob> or a blob URL (location: %s)", location)
+		}
+		path = strings.TrimPrefix(u.Path, "/")
+		o.endpoint = u.Scheme + "://" + u.Host
+		if strings.HasSuffix(u.Hostname(), hostSuffix) {
+			o.account = strings.TrimSuffix(u.Hostname(), hostSuffix)
+		}
+		if u.RawQuery != "" {
+			o.sasToken = u.RawQuery
+		}
+	}
+	slash := strings.IndexByte(path, '/')
+	if slash <= 0 || slash == len(path)-1 {
+		return nil, fmt.Errorf("invalid Azure blob location, expected a container and blob name (location: %s)", location)
+	}
+
+	c := &Client{
+		container: path[:slash],
+		blob:      path[slash+1:],
+		sasToken:  o.sasToken,
+		http:      o.http,
+	}
+	c.url = strings.TrimSuffix(o.endpoint, "/") + "/" + url.PathEscape(c.container) + "/" + escapeBlobName(c.blob)
+	if c.sasToken == "" && o.accountKey != "" {
+		key, err := newSharedKey(o.account, o.accountKey)
+		if err != nil {
+			return nil, err
+		}
+		c.sharedKey = key
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	return c, nil
+}
+
+// Size returns the size of the blob.
+func (c *Client) Size() (int64, error) {
+	if err := c.properties(); err != nil {
+		return 0, err
+	}
+	return c.size, nil
+}
+
+// Version returns the ETag of the blob, which changes whenever it is overwritten.
+func (c *Client) Version() (string, error) {
+	if err := c.properties(); err != nil {
+		return "", err
+	}
+	return c.etag, nil
+}
+
+// properties fetches the size and ETag of the blob once and reuses them for subsequent calls.
+func (c *Client) properties() error {
+	c.propsOnce.Do(func() {
+		resp, err := c.do(http.MethodHead, nil)
+		if err != nil {
+			c.propsErr = err
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			c.propsErr = c.statusError(resp, "")
+			return
+		}
+		if resp.ContentLength < 0 {
+			c.propsErr = fmt.Errorf("missing Content-Length of blob (container: %s) (blob: %s)", c.container, c.blob)
+			return
+		}
+		c.size, c.etag = resp.ContentLength, resp.Header.Get("ETag")
+	})
+	return c.propsErr
+}
+
+// ReadAt implements the io.ReaderAt interface by downloading a byte range of the blob.
+func (c *Client) ReadAt(p []byte, off int64) (n int, err error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if err := c.properties(); err != nil {
+		return 0, err
+	}
+	byteRange := fmt.Sprintf("bytes=%v-%v", off, off+int64(len(p)-1))
+	headers := http.Header{"X-Ms-Range": {byteRange}}
+	if c.etag != "" {
+		headers.Set("If-Match", c.etag)
+	}
+	resp, err := c.do(http.MethodGet, headers)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// The whole blob is sent when the range covers all of it.
+		if off != 0 || resp.ContentLength != int64(len(p)) {
+			return 0, fmt.Errorf("server ignored range request (container: %s) (blob: %s) (range: %s)", c.container, c.blob, byteRange)
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		return 0, io.EOF
+	case http.StatusPreconditionFailed:
+		return 0, fmt.Errorf("%w (container: %s) (blob: %s) (etag: %s)", ErrBlobChanged, c.container, c.blob, c.etag)
+	default:
+		return 0, c.statusError(resp, byteRange)
+	}
+	n, err = io.ReadFull(resp.Body, p)
+	if err == io.ErrUnexpectedEOF {
+		// A short range means we read past the end of the blob.
+		return n, io.EOF
+	}
+	if err != nil {
+		return n, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return n, nil
+}
+
+func (c *Client) do(method string, headers http.Header) (*http.Response, error) {
+	target := c.url
+	if c.sasToken != "" {
+		target += "?" + c.sasToken
+	}
+	req, err := http.NewRequest(method, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request (container: %s) (blob: %s): %w", c.container, c.blob, err)
+	}
+	for key, values := range headers {
+		req.Header[key] = values
+	}
+	req.Header.Set("X-Ms-Version", apiVersion)
+	req.Header.Set("X-Ms-Date", time.Now().UTC().Format(http.TimeFormat))
+	if c.sharedKey != nil {
+		c.sharedKey.sign(req)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed %s request (container: %s) (blob: %s): %w", method, c.container, c.blob, err)
+	}
+	return resp, nil
+}
+
+// statusError describes an unexpected response, including the error code sent by the service.
+func (c *Client) statusError(resp *http.Response, byteRange string) error {
+	code := resp.Header.Get("X-Ms-Error-Code")
+	if code == "" {
+		code = "unknown"
+	}
+	if byteRange != "" {
+		return fmt.Errorf("unexpected status (container: %s) (blob: %s) (range: %s): %s (code: %s)", c.container, c.blob, byteRange, resp.Status, code)
+	}
+	return fmt.Errorf("unexpected status (container: %s) (blob: %s): %s (code: %s)", c.container, c.blob, resp.Status, code)
+}
+
+// escapeBlobName escapes each segment of a blob name, keeping the slashes between them.
+func escapeBlobName(name string) string {
+	segments := strings.Split(name, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,174 @@
+package blob
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSharedKeySignature(t *testing.T) {
+	key, err := newSharedKey(devStoreAccount, devStoreKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodGet, devStoreEndpoint+"/container/dir/my%20archive.zip?b=2&a=3&a=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Ms-Date", "Sat, 22 Jan 2022 00:00:00 GMT")
+	req.Header.Set("X-Ms-Version", apiVersion)
+	req.Header.Set("X-Ms-Range", "bytes=0-99")
+	req.Header.Set("If-Match", `"0x8D9DD2B3C4E5F60"`)
+	key.sign(req)
+
+	// Computed independently from the string to sign:
+	// "GET\n\n\n\n\n\n\n\n\"0x8D9DD2B3C4E5F60\"\n\n\n\n" +
+	// "x-ms-date:Sat, 22 Jan 2022 00:00:00 GMT\nx-ms-range:bytes=0-99\nx-ms-version:2020-10-02\n" +
+	// "/devstoreaccount1/devstoreaccount1/container/dir/my%20archive.zip\na:1,3\nb:2"
+	want := "SharedKey devstoreaccount1:VHUO6nxD2DVhnb8VZT4Nuj9ekcY2gpbVsVX8sJXhJIA="
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %q, want %q", got, want)
+	}
+}
+
+// fakeBlobService serves a single blob, checking each request with authorize.
+type fakeBlobService struct {
+	mu        sync.Mutex
+	path      string
+	data      []byte
+	etag      string
+	authorize func(r *http.Request) bool
+	requests  []*http.Request
+}
+
+func (s *fakeBlobService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r)
+	etag := s.etag
+	s.mu.Unlock()
+	if !s.authorize(r) {
+		w.Header().Set("X-Ms-Error-Code", "AuthenticationFailed")
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if r.URL.EscapedPath() != s.path {
+		w.Header().Set("X-Ms-Error-Code", "BlobNotFound")
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	// ServeContent answers HEAD, Range and If-Match requests.
+	if byteRange := r.Header.Get("X-Ms-Range"); byteRange != "" {
+		r.Header.Set("Range", byteRange)
+	}
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(s.data))
+}
+
+func TestAuthentication(t *testing.T) {
+	tests := []struct {
+		name      string
+		location  func(endpoint string) string
+		opts      func(endpoint string) []Option
+		authorize func(r *http.Request) bool
+	}{
+		{
+			name: "SAS token in URL",
+			location: func(endpoint string) string {
+				return endpoint + "/devstoreaccount1/container/dir/my%20archive.zip?sv=2020-10-02&sp=r&sig=c2ln"
+			},
+			authorize: func(r *http.Request) bool {
+				return r.URL.Query().Get("sig") == "c2ln" && r.Header.Get("Authorization") == ""
+			},
+		},
+		{
+			name:     "SAS token option",
+			location: func(string) string { return "az://container/dir/my archive.zip" },
+			opts: func(endpoint string) []Option {
+				return []Option{WithEndpoint(endpoint + "/devstoreaccount1"), WithSASToken("?sv=2020-10-02&sig=c2ln"), WithAccountKey(devStoreKey)}
+			},
+			authorize: func(r *http.Request) bool {
+				return r.URL.Query().Get("sig") == "c2ln" && r.Header.Get("Authorization") == ""
+			},
+		},
+		{
+			name:     "shared key",
+			location: func(string) string { return "az://container/dir/my archive.zip" },
+			opts: func(endpoint string) []Option {
+				return []Option{WithEndpoint(endpoint + "/devstoreaccount1"), WithAccount(devStoreAccount), WithAccountKey(devStoreKey)}
+			},
+			authorize: func(r *http.Request) bool {
+				// Sign a copy of the request, as the server would, and compare.
+				got := r.Header.Get("Authorization")
+				clone := r.Clone(r.Context())
+				clone.Header.Del("Authorization")
+				key, _ := newSharedKey(devStoreAccount, devStoreKey)
+				key.sign(clone)
+				return strings.HasPrefix(got, "SharedKey devstoreaccount1:") && got == clone.Header.Get("Authorization")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &fakeBlobService{
+				path:      "/devstoreaccount1/container/dir/my%20archive.zip",
+				data:      []byte("0123456789"),
+				etag:      `"0x1"`,
+				authorize: tt.authorize,
+			}
+			srv := httptest.NewServer(s)
+			defer srv.Close()
+			var opts []Option
+			if tt.opts != nil {
+				opts = tt.opts(srv.URL)
+			}
+			c, err := NewClient(tt.location(srv.URL), opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size, err := c.Size(); err != nil || size != 10 {
+				t.Fatalf("Size() = %d, %v, want 10", size, err)
+			}
+			p := make([]byte, 4)
+			if n, err := c.ReadAt(p, 2); err != nil || string(p[:n]) != "2345" {
+				t.Errorf("ReadAt() = %q, %v, want %q", p[:n], err, "2345")
+			}
+
+			// Reads of an overwritten blob fail its If-Match condition.
+			s.mu.Lock()
+			s.etag = `"0x2"`
+			s.mu.Unlock()
+			if _, err := c.ReadAt(p, 2); !errors.Is(err, ErrBlobChanged) {
+				t.Errorf("ReadAt() of an overwritten blob: error = %v, want %v", err, ErrBlobChanged)
+			}
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			for _, r := range s.requests {
+				if r.Method == http.MethodGet && r.Header.Get("If-Match") != `"0x1"` {
+					t.Errorf("GET with If-Match %q, want %q", r.Header.Get("If-Match"), `"0x1"`)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthenticationFailed(t *testing.T) {
+	s := &fakeBlobService{
+		path:      "/devstoreaccount1/container/archive.zip",
+		authorize: func(r *http.Request) bool { return false },
+	}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	c, err := NewClient("az://container/archive.zip", WithEndpoint(srv.URL+"/devstoreaccount1"), WithAccount(devStoreAccount), WithAccountKey(devStoreKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Size(); err == nil || !strings.Contains(err.Error(), "AuthenticationFailed") {
+		t.Errorf("Size() error = %v, want the AuthenticationFailed error code", err)
+	}
+}
@@ -0,0 +1,150 @@
+package blob
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Account, key and endpoint of the Azurite storage emulator, used by "UseDevelopmentStorage=true".
+const (
+	devStoreAccount  = "devstoreaccount1"
+	devStoreKey      = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+	devStoreEndpoint = "http://127.0.0.1:10000/devstoreaccount1"
+)
+
+// connectionString holds the settings of a storage account connection string that apply to blobs.
+type connectionString struct {
+	account  string
+	key      string
+	sasToken string
+	endpoint string
+}
+
+// parseConnectionString parses a connection string as shown in the Azure portal, e.g.
+// "DefaultEndpointsProtocol=https;AccountName=myaccount;AccountKey=...;EndpointSuffix=core.windows.net".
+func parseConnectionString(s string) (*connectionString, error) {
+	settings := make(map[string]string)
+	for _, part := range strings.Split(s, ";") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid connection string setting, expected \"Key=Value\"")
+		}
+		settings[strings.ToLower(strings.TrimSpace(kv[0]))] = strings.TrimSpace(kv[1])
+	}
+	if strings.EqualFold(settings["usedevelopmentstorage"], "true") {
+		return &connectionString{account: devStoreAccount, key: devStoreKey, endpoint: devStoreEndpoint}, nil
+	}
+	cs := &connectionString{
+		account:  settings["accountname"],
+		key:      settings["accountkey"],
+		sasToken: settings["sharedaccesssignature"],
+		endpoint: settings["blobendpoint"],
+	}
+	if cs.endpoint == "" {
+		if cs.account == "" {
+			return nil, fmt.Errorf("connection string must contain AccountName or BlobEndpoint")
+		}
+		protocol, suffix := settings["defaultendpointsprotocol"], settings["endpointsuffix"]
+		if protocol == "" {
+			protocol = "https"
+		}
+		if suffix == "" {
+			suffix = "core.windows.net"
+		}
+		cs.endpoint = fmt.Sprintf("%s://%s.blob.%s", protocol, cs.account, suffix)
+	}
+	return cs, nil
+}
+
+// sharedKey signs requests with a storage account key, see
+// https://learn.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key
+type sharedKey struct {
+	account string
+	key     []byte
+}
+
+func newSharedKey(account, key string) (*sharedKey, error) {
+	if account == "" {
+		return nil, fmt.Errorf("an account name is required to authenticate with an account key")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid account key, expected base64: %w", err)
+	}
+	return &sharedKey{account: account, key: decoded}, nil
+}
+
+// sign sets the Authorization header of the request, which must already have its x-ms-date header set.
+func (k *sharedKey) sign(req *http.Request) {
+	h := req.Header
+	contentLength := h.Get("Content-Length")
+	if contentLength == "0" {
+		contentLength = ""
+	}
+	stringToSign := strings.Join([]string{
+		req.Method,
+		h.Get("Content-Encoding"),
+		h.Get("Content-Language"),
+		contentLength,
+		h.Get("Content-MD5"),
+		h.Get("Content-Type"),
+		"", // Date, replaced by x-ms-date
+		h.Get("If-Modified-Since"),
+		h.Get("If-Match"),
+		h.Get("If-None-Match"),
+		h.Get("If-Unmodified-Since"),
+		h.Get("Range"),
+		canonicalizedHeaders(h) + k.canonicalizedResource(req.URL),
+	}, "\n")
+	mac := hmac.New(sha256.New, k.key)
+	mac.Write([]byte(stringToSign))
+	h.Set("Authorization", "SharedKey "+k.account+":"+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+}
+
+// canonicalizedHeaders returns the x-ms- headers, sorted by name, one "name:value\n" line each.
+func canonicalizedHeaders(h http.Header) string {
+	var names []string
+	for name := range h {
+		if name = strings.ToLower(name); strings.HasPrefix(name, "x-ms-") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + ":" + strings.Join(h.Values(name), ",") + "\n")
+	}
+	return b.String()
+}
+
+// canonicalizedResource returns the account, path and query parameters sorted by name.
+func (k *sharedKey) canonicalizedResource(u *url.URL) string {
+	var b strings.Builder
+	b.WriteString("/" + k.account)
+	if path := u.EscapedPath(); path != "" {
+		b.WriteString(path)
+	} else {
+		b.WriteString("/")
+	}
+	query := u.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		values := query[name]
+		sort.Strings(values)
+		b.WriteString("\n" + strings.ToLower(name) + ":" + strings.Join(values, ","))
+	}
+	return b.String()
+}
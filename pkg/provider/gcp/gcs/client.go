@@ -0,0 +1,250 @@
+package gcs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/alec-rabold/zipspy/pkg/zipspy"
+	"golang.org/x/oauth2/google"
+)
+
+var _ zipspy.Reader = (*Client)(nil)
+var _ zipspy.Versioner = (*Client)(nil)
+
+// DefaultEndpoint is the endpoint of the Google Cloud Storage JSON API.
+const DefaultEndpoint = "https://storage.googleapis.com"
+
+// readOnlyScope is the OAuth2 scope requested for application default credentials.
+const readOnlyScope = "https://www.googleapis.com/auth/devstorage.read_only"
+
+// ErrObjectChanged is returned when the object is overwritten while it is being read.
+var ErrObjectChanged = errors.New("object changed while reading")
+
+// Client implements the zipspy.Reader interface over ranged downloads of a Google Cloud Storage object.
+// Every download is made conditional on the generation returned by the first metadata request,
+// so that an archive overwritten mid-read fails with ErrObjectChanged rather than returning corrupt data.
+type Client struct {
+	bucket   string
+	object   string
+	endpoint string
+	http     *http.Client
+
+	attrsOnce sync.Once
+	attrs     objectAttrs
+	attrsErr  error
+}
+
+// objectAttrs holds the fields we care about from an object resource of the JSON API.
+type objectAttrs struct {
+	Size       int64 `json:"size,string"`
+	Generation int64 `json:"generation,string"`
+}
+
+// Option configures a GCS client.
+type Option func(*options)
+
+type options struct {
+	endpoint  string
+	anonymous bool
+	http      *http.Client
+}
+
+// WithEndpoint sets a custom endpoint, e.g. for a local fake GCS server ("http://localhost:4443").
+func WithEndpoint(endpoint string) Option {
+	return func(o *options) {
+		o.endpoint = endpoint
+	}
+}
+
+// WithoutAuthentication sends requests without credentials, for public buckets and fake servers.
+func WithoutAuthentication() Option {
+	return func(o *options) {
+		o.anonymous = true
+	}
+}
+
+// WithHTTPClient overrides the underlying HTTP client, which is then responsible for authentication.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.http = client
+	}
+}
+
+// NewCreator returns a provider constructor for "gs://" locations using the given options.
+// The provider registry strips the protocol from the location, so it is added back here.
+func NewCreator(opts ...Option) func(location string) (zipspy.Reader, error) {
+	return func(location string) (zipspy.Reader, error) {
+		return NewClient("gs://"+location, opts...)
+	}
+}
+
+// NewClient creates a new GCS file reader for a location of the form "gs://bucket/object".
+// Requests are authenticated with application default credentials, unless the STORAGE_EMULATOR_HOST
+// environment variable is set, in which case requests are sent to the emulator without credentials
+// (like the official client libraries).
+func NewClient(location string, opts ...Option) (*Client, error) {
+	path := strings.TrimPrefix(location, "gs://")
+	slash := strings.IndexByte(path, '/')
+	if path == location || slash <= 0 || slash == len(path)-1 {
+		return nil, fmt.Errorf("invalid GCS URI, expected gs://<bucket>/<object> (location: %s)", location)
+	}
+	o := options{endpoint: DefaultEndpoint}
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		o.endpoint, o.anonymous = host, true
+		if !strings.Contains(host, "://") {
+			o.endpoint = "http://" + host
+		}
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	client := o.http
+	if client == nil {
+		client = http.DefaultClient
+		if !o.anonymous {
+			var err error
+			if client, err = google.DefaultClient(context.Background(), readOnlyScope); err != nil {
+				return nil, fmt.Errorf("failed to find application default credentials: %w", err)
+			}
+		}
+	}
+	return &Client{
+		bucket:   path[:slash],
+		object:   path[slash+1:],
+		endpoint: strings.TrimSuffix(o.endpoint, "/"),
+		http:     client,
+	}, nil
+}
+
+// Size returns the size of the object.
+func (c *Client) Size() (int64, error) {
+	attrs, err := c.objectAttrs()
+	if err != nil {
+		return 0, err
+	}
+	return attrs.Size, nil
+}
+
+// Version returns the generation of the object, which changes whenever it is overwritten.
+func (c *Client) Version() (string, error) {
+	attrs, err := c.objectAttrs()
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(attrs.Generation, 10), nil
+}
+
+// objectAttrs fetches the object's metadata once and reuses it for subsequent calls.
+func (c *Client) objectAttrs() (*objectAttrs, error) {
+	c.attrsOnce.Do(func() {
+		resp, err := c.do(c.objectURL(nil), "")
+		if err != nil {
+			c.attrsErr = err
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			c.attrsErr = c.statusError(resp, "")
+			return
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&c.attrs); err != nil {
+			c.attrsErr = fmt.Errorf("failed to decode object metadata (bucket: %s) (object: %s): %w", c.bucket, c.object, err)
+		}
+	})
+	return &c.attrs, c.attrsErr
+}
+
+// ReadAt implements the io.ReaderAt interface by downloading a byte range of the object.
+func (c *Client) ReadAt(p []byte, off int64) (n int, err error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	attrs, err := c.objectAttrs()
+	if err != nil {
+		return 0, err
+	}
+	byteRange := fmt.Sprintf("bytes=%v-%v", off, off+int64(len(p)-1))
+	resp, err := c.do(c.objectURL(url.Values{
+		"alt":               {"media"},
+		"ifGenerationMatch": {strconv.FormatInt(attrs.Generation, 10)},
+	}), byteRange)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// The whole object is sent when the range covers all of it.
+		if off != 0 || resp.ContentLength != int64(len(p)) {
+			return 0, fmt.Errorf("server ignored range request (bucket: %s) (object: %s) (range: %s)", c.bucket, c.object, byteRange)
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		return 0, io.EOF
+	case http.StatusPreconditionFailed:
+		return 0, fmt.Errorf("%w (bucket: %s) (object: %s) (generation: %d)", ErrObjectChanged, c.bucket, c.object, attrs.Generation)
+	default:
+		return 0, c.statusError(resp, byteRange)
+	}
+	n, err = io.ReadFull(resp.Body, p)
+	if err == io.ErrUnexpectedEOF {
+		// A short range means we read past the end of the object.
+		return n, io.EOF
+	}
+	if err != nil {
+		return n, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return n, nil
+}
+
+// objectURL returns the JSON API URL of the object with the given query parameters.
+func (c *Client) objectURL(query url.Values) string {
+	u := fmt.Sprintf("%s/storage/v1/b/%s/o/%s", c.endpoint, url.PathEscape(c.bucket), url.PathEscape(c.object))
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+func (c *Client) do(target, byteRange string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request (bucket: %s) (object: %s): %w", c.bucket, c.object, err)
+	}
+	if byteRange != "" {
+		req.Header.Set("Range", byteRange)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed GET request (bucket: %s) (object: %s): %w", c.bucket, c.object, err)
+	}
+	return resp, nil
+}
+
+// statusError describes an unexpected response, including the error message sent by the server.
+func (c *Client) statusError(resp *http.Response, byteRange string) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	msg := strings.TrimSpace(string(body))
+	var apiErr struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &apiErr) == nil && apiErr.Error.Message != "" {
+		msg = apiErr.Error.Message
+	}
+	if byteRange != "" {
+		return fmt.Errorf("unexpected status (bucket: %s) (object: %s) (range: %s): %s: %s", c.bucket, c.object, byteRange, resp.Status, msg)
+	}
+	return fmt.Errorf("unexpected status (bucket: %s) (object: %s): %s: %s", c.bucket, c.object, resp.Status, msg)
+}
@@ -0,0 +1,164 @@
+package gcs
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alec-rabold/zipspy/pkg/zipspy"
+)
+
+// fakeGCS serves objects of the JSON API by "bucket/object", like an emulator.
+type fakeGCS struct {
+	mu         sync.Mutex
+	objects    map[string][]byte
+	generation int64
+	requests   []*http.Request
+}
+
+func (s *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r)
+	generation := s.generation
+	s.mu.Unlock()
+
+	// Object names are escaped, so that they may contain slashes.
+	parts := strings.Split(strings.TrimPrefix(r.URL.EscapedPath(), "/storage/v1/b/"), "/")
+	if len(parts) != 3 || parts[1] != "o" {
+		http.NotFound(w, r)
+		return
+	}
+	bucket, _ := url.PathUnescape(parts[0])
+	object, _ := url.PathUnescape(parts[2])
+	data, ok := s.objects[bucket+"/"+object]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"error": {"code": 404, "message": "No such object: %s/%s"}}`, bucket, object)
+		return
+	}
+	if match := r.URL.Query().Get("ifGenerationMatch"); match != "" && match != strconv.FormatInt(generation, 10) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		fmt.Fprint(w, `{"error": {"code": 412, "message": "At least one of the pre-conditions you specified did not hold."}}`)
+		return
+	}
+	if r.URL.Query().Get("alt") != "media" {
+		fmt.Fprintf(w, `{"name": %q, "size": "%d", "generation": "%d"}`, object, len(data), generation)
+		return
+	}
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
+
+// newEmulator starts a fake GCS server and points STORAGE_EMULATOR_HOST to it.
+func newEmulator(t *testing.T, objects map[string][]byte) *fakeGCS {
+	t.Helper()
+	s := &fakeGCS{objects: objects, generation: 1}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	t.Setenv("STORAGE_EMULATOR_HOST", strings.TrimPrefix(srv.URL, "http://"))
+	return s
+}
+
+func TestEmulator(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("notes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("Notes from file."))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s := newEmulator(t, map[string][]byte{"my-bucket/dir/archive.zip": buf.Bytes()})
+
+	c, err := NewClient("gs://my-bucket/dir/archive.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size, err := c.Size(); err != nil || size != int64(buf.Len()) {
+		t.Errorf("Size() = %d, %v, want %d", size, err, buf.Len())
+	}
+	if version, err := c.Version(); err != nil || version != "1" {
+		t.Errorf("Version() = %q, %v, want %q", version, err, "1")
+	}
+	zc, err := zipspy.NewClient(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := zc.GetFiles([]string{"notes.txt"})
+	if len(files) != 1 {
+		t.Fatalf("GetFiles() returned %d files, want 1", len(files))
+	}
+	rc, err := files[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if b, err := io.ReadAll(rc); err != nil || string(b) != "Notes from file." {
+		t.Errorf("notes.txt = %q, %v, want %q", b, err, "Notes from file.")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.requests {
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			t.Errorf("request to the emulator with Authorization %q", auth)
+		}
+		if r.URL.Query().Get("alt") == "media" && (r.Header.Get("Range") == "" || r.URL.Query().Get("ifGenerationMatch") != "1") {
+			t.Errorf("download with Range %q and ifGenerationMatch %q, want a conditional range request",
+				r.Header.Get("Range"), r.URL.Query().Get("ifGenerationMatch"))
+		}
+	}
+}
+
+func TestObjectChanged(t *testing.T) {
+	s := newEmulator(t, map[string][]byte{"my-bucket/archive.zip": []byte("0123456789")})
+	c, err := NewClient("gs://my-bucket/archive.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := make([]byte, 4)
+	if n, err := c.ReadAt(p, 2); err != nil || string(p[:n]) != "2345" {
+		t.Errorf("ReadAt() = %q, %v, want %q", p[:n], err, "2345")
+	}
+	// Reads past the end of the object are short.
+	if n, err := c.ReadAt(p, 8); err != io.EOF || string(p[:n]) != "89" {
+		t.Errorf("ReadAt() at the end = %q, %v, want %q, %v", p[:n], err, "89", io.EOF)
+	}
+
+	s.mu.Lock()
+	s.generation++
+	s.mu.Unlock()
+	if _, err := c.ReadAt(p, 2); !errors.Is(err, ErrObjectChanged) {
+		t.Errorf("ReadAt() of an overwritten object: error = %v, want %v", err, ErrObjectChanged)
+	}
+}
+
+func TestObjectNotFound(t *testing.T) {
+	newEmulator(t, map[string][]byte{})
+	c, err := NewClient("gs://my-bucket/missing.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Size(); err == nil || !strings.Contains(err.Error(), "No such object: my-bucket/missing.zip") {
+		t.Errorf("Size() error = %v, want the server's message", err)
+	}
+}
+
+func TestInvalidLocation(t *testing.T) {
+	for _, location := range []string{"gs://my-bucket", "gs://my-bucket/", "gs:///archive.zip", "s3://my-bucket/archive.zip"} {
+		if _, err := NewClient(location, WithoutAuthentication()); err == nil {
+			t.Errorf("NewClient(%q): no error", location)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/alec-rabold/zipspy/pkg/zipspy"
+)
+
+var _ zipspy.Reader = (*Client)(nil)
+var _ zipspy.Versioner = (*Client)(nil)
+
+// ErrRangeNotSupported is returned when a server ignores the Range header of a request.
+var ErrRangeNotSupported = errors.New("server does not support range requests")
+
+// Client implements the zipspy.Reader interface over HTTP range requests.
+type Client struct {
+	url     string
+	headers http.Header
+	http    *http.Client
+
+	// resolved is the final URL after following redirects from the first request.
+	// Custom headers are not sent to it when it is on another host,
+	// matching how net/http drops sensitive headers on redirects.
+	resolved          string
+	resolvedCrossHost bool
+	resolvedMutex     sync.Mutex
+
+	// statOnce guards the size and version learned from the first request.
+	statOnce sync.Once
+	size     int64
+	version  string
+	statErr  error
+}
+
+// Option configures an HTTP client.
+type Option func(*Client)
+
+// WithHeader adds a header sent with every request.
+func WithHeader(key, value string) Option {
+	return func(c *Client) {
+		c.headers.Add(key, value)
+	}
+}
+
+// WithBearerToken sets the Authorization header to the given bearer token.
+func WithBearerToken(token string) Option {
+	return func(c *Client) {
+		c.headers.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// WithHTTPClient overrides the underlying HTTP client (e.g. for custom transports or tests).
+// Its CheckRedirect policy decides which headers are sent along redirects of the first request.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		c.http = client
+	}
+}
+
+// NewCreator returns a provider constructor for locations with the given scheme ("http" or "https").
+// The provider registry strips the protocol from the location, so it is added back here.
+func NewCreator(scheme string, opts ...Option) func(location string) (zipspy.Reader, error) {
+	return func(location string) (zipspy.Reader, error) {
+		return NewClient(scheme+"://"+location, opts...)
+	}
+}
+
+// NewClient creates a new HTTP file reader for the given URL.
+func NewClient(url string, opts ...Option) (*Client, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("unsupported URL scheme (url: %s)", url)
+	}
+	c := &Client{
+		url:     url,
+		headers: make(http.Header),
+	}
+	c.http = &http.Client{CheckRedirect: c.checkRedirect}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// Size returns the size of the remote file from the Content-Length of a HEAD request.
+// If the server rejects HEAD requests or does not report a length,
+// the total from the Content-Range of a one byte GET is used instead.
+func (c *Client) Size() (int64, error) {
+	c.statOnce.Do(c.stat)
+	return c.size, c.statErr
+}
+
+// Version returns the ETag of the remote file, or its Last-Modified time if there is no ETag.
+func (c *Client) Version() (string, error) {
+	c.statOnce.Do(c.stat)
+	if c.statErr != nil {
+		return "", c.statErr
+	}
+	if c.version == "" {
+		return "", fmt.Errorf("server did not report an ETag or Last-Modified header (url: %s)", c.url)
+	}
+	return c.version, nil
+}
+
+func (c *Client) stat() {
+	resp, err := c.do(http.MethodHead, "")
+	if err != nil {
+		c.statErr = err
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK && resp.ContentLength >= 0 {
+		c.size, c.version = resp.ContentLength, responseVersion(resp)
+		return
+	}
+
+	resp, err = c.do(http.MethodGet, "bytes=0-0")
+	if err != nil {
+		c.statErr = err
+		return
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		c.statErr = fmt.Errorf("%w (url: %s)", ErrRangeNotSupported, c.url)
+		return
+	default:
+		c.statErr = fmt.Errorf("unexpected status for GET request (url: %s): %s", c.url, resp.Status)
+		return
+	}
+	_, _, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+	if err != nil {
+		c.statErr = fmt.Errorf("failed to determine size (url: %s): %w", c.url, err)
+		return
+	}
+	if total < 0 {
+		c.statErr = fmt.Errorf("server did not report a size (url: %s)", c.url)
+		return
+	}
+	c.size, c.version = total, responseVersion(resp)
+}
+
+// responseVersion identifies the version of a response's content.
+func responseVersion(resp *http.Response) string {
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		return etag
+	}
+	if modified := resp.Header.Get("Last-Modified"); modified != "" {
+		return modified
+	}
+	return ""
+}
+
+// ReadAt implements the io.ReaderAt interface by downloading a byte range of the file.
+func (c *Client) ReadAt(p []byte, off int64) (n int, err error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	byteRange := fmt.Sprintf("bytes=%v-%v", off, off+int64(len(p)-1))
+	resp, err := c.do(http.MethodGet, byteRange)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusRequestedRangeNotSatisfiable:
+		return 0, io.EOF
+	case http.StatusOK:
+		// The server ignored the range and is sending the whole file; never read it.
+		return 0, fmt.Errorf("%w (url: %s) (range: %s)", ErrRangeNotSupported, c.url, byteRange)
+	default:
+		return 0, fmt.Errorf("unexpected status for GET request (url: %s) (range: %s): %s", c.url, byteRange, resp.Status)
+	}
+	if start, _, _, err := parseContentRange(resp.Header.Get("Content-Range")); err == nil && start != off {
+		return 0, fmt.Errorf("server returned the wrong range (url: %s) (range: %s) (start: %d)", c.url, byteRange, start)
+	}
+	n, err = io.ReadFull(resp.Body, p)
+	if err == io.ErrUnexpectedEOF {
+		// A short range means we read past the end of the file.
+		return n, io.EOF
+	}
+	if err != nil {
+		return n, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return n, nil
+}
+
+// checkRedirect drops the custom headers from redirects to another host, which net/http
+// would otherwise copy (it only drops sensitive headers such as Authorization).
+func (c *Client) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if req.URL.Host != via[0].URL.Host {
+		for key := range c.headers {
+			req.Header.Del(key)
+		}
+	}
+	return nil
+}
+
+func (c *Client) do(method, byteRange string) (*http.Response, error) {
+	target, crossHost := c.target()
+	req, err := http.NewRequest(method, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request (url: %s): %w", c.url, err)
+	}
+	if !crossHost {
+		for key, values := range c.headers {
+			req.Header[key] = values
+		}
+	}
+	if byteRange != "" {
+		req.Header.Set("Range", byteRange)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed %s request (url: %s): %w", method, c.url, err)
+	}
+	c.resolvedMutex.Lock()
+	defer c.resolvedMutex.Unlock()
+	if c.resolved == "" && resp.Request != nil && resp.Request.URL != nil {
+		c.resolved = resp.Request.URL.String()
+		c.resolvedCrossHost = resp.Request.URL.Host != req.URL.Host
+	}
+	return resp, nil
+}
+
+// target returns the URL requests should be sent to,
+// preferring the destination of any redirects followed by the first request.
+func (c *Client) target() (string, bool) {
+	c.resolvedMutex.Lock()
+	defer c.resolvedMutex.Unlock()
+	if c.resolved != "" {
+		return c.resolved, c.resolvedCrossHost
+	}
+	return c.url, false
+}
+
+// parseContentRange parses a header of the form "bytes start-end/total".
+// The total is -1 if the server reported it as unknown ("*").
+func parseContentRange(header string) (start, end, total int64, err error) {
+	if !strings.HasPrefix(header, "bytes ") {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range header: %q", header)
+	}
+	spec := strings.TrimPrefix(header, "bytes ")
+	slash := strings.IndexByte(spec, '/')
+	dash := strings.IndexByte(spec, '-')
+	if slash < 0 || dash < 0 || dash > slash {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range header: %q", header)
+	}
+	if start, err = strconv.ParseInt(spec[:dash], 10, 64); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range header: %q", header)
+	}
+	if end, err = strconv.ParseInt(spec[dash+1:slash], 10, 64); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range header: %q", header)
+	}
+	total = -1
+	if s := spec[slash+1:]; s != "*" {
+		if total, err = strconv.ParseInt(s, 10, 64); err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid Content-Range header: %q", header)
+		}
+	}
+	return start, end, total, nil
+}
@@ -0,0 +1,155 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var content = []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+
+// serveContent serves content with support for HEAD and range requests.
+func serveContent(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("ETag", `"v1"`)
+	http.ServeContent(w, r, "archive.zip", time.Time{}, bytes.NewReader(content))
+}
+
+func TestSizeFromHead(t *testing.T) {
+	var methods []string
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		methods = append(methods, r.Method)
+		mu.Unlock()
+		serveContent(w, r)
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL + "/archive.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err := c.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(len(content)) {
+		t.Errorf("Size() = %d, want %d", size, len(content))
+	}
+	if version, err := c.Version(); err != nil || version != `"v1"` {
+		t.Errorf("Version() = %q, %v, want %q", version, err, `"v1"`)
+	}
+	if len(methods) != 1 || methods[0] != http.MethodHead {
+		t.Errorf("requests = %v, want a single HEAD", methods)
+	}
+
+	p := make([]byte, 5)
+	if n, err := c.ReadAt(p, 10); err != nil || string(p[:n]) != "abcde" {
+		t.Errorf("ReadAt() = %q, %v, want %q", p[:n], err, "abcde")
+	}
+}
+
+func TestSizeFallsBackToRangeRequest(t *testing.T) {
+	var ranges []string
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		mu.Unlock()
+		serveContent(w, r)
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL + "/archive.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err := c.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(len(content)) {
+		t.Errorf("Size() = %d, want %d", size, len(content))
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=0-0" {
+		t.Errorf("ranges = %q, want [bytes=0-0]", ranges)
+	}
+}
+
+func TestRangeNotSupported(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		// Ignore the Range header and send the whole file.
+		w.WriteHeader(http.StatusOK)
+		w.Write(content)
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL + "/archive.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ReadAt(make([]byte, 4), 8); !errors.Is(err, ErrRangeNotSupported) {
+		t.Errorf("ReadAt() error = %v, want ErrRangeNotSupported", err)
+	}
+	if _, err := c.Size(); !errors.Is(err, ErrRangeNotSupported) {
+		t.Errorf("Size() error = %v, want ErrRangeNotSupported", err)
+	}
+}
+
+func TestHeadersDroppedOnCrossHostRedirect(t *testing.T) {
+	var leaked []string
+	var mu sync.Mutex
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, key := range []string{"Authorization", "X-Api-Key"} {
+			if v := r.Header.Get(key); v != "" {
+				mu.Lock()
+				leaked = append(leaked, r.Method+" "+key+": "+v)
+				mu.Unlock()
+			}
+		}
+		serveContent(w, r)
+	}))
+	defer target.Close()
+	// Same address, but a different host name, so that the redirect crosses hosts.
+	targetURL := strings.Replace(target.URL, "127.0.0.1", "localhost", 1)
+
+	var originAuth []string
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		originAuth = append(originAuth, r.Header.Get("X-Api-Key"))
+		mu.Unlock()
+		http.Redirect(w, r, targetURL+r.URL.Path, http.StatusFound)
+	}))
+	defer origin.Close()
+
+	c, err := NewClient(origin.URL+"/archive.zip", WithHeader("X-Api-Key", "secret"), WithBearerToken("token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size, err := c.Size(); err != nil || size != int64(len(content)) {
+		t.Fatalf("Size() = %d, %v, want %d", size, err, len(content))
+	}
+	p := make([]byte, 3)
+	if n, err := c.ReadAt(p, 0); err != nil || string(p[:n]) != "012" {
+		t.Errorf("ReadAt() = %q, %v, want %q", p[:n], err, "012")
+	}
+	if len(originAuth) != 1 || originAuth[0] != "secret" {
+		t.Errorf("origin received X-Api-Key %q, want a single request with the header", originAuth)
+	}
+	if len(leaked) > 0 {
+		t.Errorf("headers sent to the redirect target: %q", leaked)
+	}
+}
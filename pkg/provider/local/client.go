@@ -8,6 +8,7 @@ import (
 )
 
 var _ zipspy.Reader = (*Client)(nil)
+var _ zipspy.Versioner = (*Client)(nil)
 
 // Client implements the <<SOMETHING>> interface.
 type Client struct {
@@ -28,6 +29,15 @@ func (c *Client) Size() (int64, error) {
 	return file.Size(), nil
 }
 
+// Version returns the modification time and size of the file.
+func (c *Client) Version() (string, error) {
+	file, err := os.Stat(c.filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file (name: %s): %w", c.filePath, err)
+	}
+	return fmt.Sprintf("%d-%d", file.ModTime().UnixNano(), file.Size()), nil
+}
+
 // ReadAt implements the io.ReaderAt interface by downloading a byte range of the object.
 func (c *Client) ReadAt(p []byte, off int64) (n int, err error) {
 	file, err := os.Open(c.filePath)
@@ -18,6 +18,10 @@ type Registry struct {
 type Provider struct {
 	// Protocol identifies the provider from a given location.
 	Protocol string
+	// Match identifies the provider from the whole location instead of its protocol,
+	// for services sharing a protocol with another provider (e.g. https). It is checked
+	// before any protocol, and the location is passed to CreatePlugin unchanged.
+	Match func(location string) bool
 	// CreatePlugin defines how to instantiate a new zipspy plugin.
 	CreatePlugin func(location string) (zipspy.Reader, error)
 }
@@ -42,6 +46,13 @@ func WithProvider(name, protocol string, createPlugin func(location string) (zip
 	}
 }
 
+// WithMatcher is a helper function to register plugins identified by a match function during registry creation.
+func WithMatcher(name string, match func(location string) bool, createPlugin func(location string) (zipspy.Reader, error)) registryOption {
+	return func(r *Registry) {
+		r.MustRegisterMatcher(name, match, createPlugin)
+	}
+}
+
 // RegisterProvider defines a new provider with the given name and protocol.
 func (r *Registry) RegisterProvider(name, protocol string, createPlugin func(location string) (zipspy.Reader, error)) error {
 	r.providersMutex.Lock()
@@ -50,7 +61,7 @@ func (r *Registry) RegisterProvider(name, protocol string, createPlugin func(loc
 		return fmt.Errorf("plugin with name %s already exists", name)
 	}
 	for name, provider := range r.providers {
-		if protocol == provider.Protocol {
+		if provider.Match == nil && protocol == provider.Protocol {
 			return fmt.Errorf("plugin with name %s already implements protocol %s", name, protocol)
 		}
 	}
@@ -68,12 +79,38 @@ func (r *Registry) MustRegisterProvider(name, protocol string, createPlugin func
 	}
 }
 
+// RegisterMatcher defines a new provider with the given name, for the locations accepted by match.
+func (r *Registry) RegisterMatcher(name string, match func(location string) bool, createPlugin func(location string) (zipspy.Reader, error)) error {
+	r.providersMutex.Lock()
+	defer r.providersMutex.Unlock()
+	if _, exists := r.providers[name]; exists {
+		return fmt.Errorf("plugin with name %s already exists", name)
+	}
+	r.providers[name] = Provider{
+		Match:        match,
+		CreatePlugin: createPlugin,
+	}
+	return nil
+}
+
+// MustRegisterMatcher is a helper function that panics when RegisterMatcher fails.
+func (r *Registry) MustRegisterMatcher(name string, match func(location string) bool, createPlugin func(location string) (zipspy.Reader, error)) {
+	if err := r.RegisterMatcher(name, match, createPlugin); err != nil {
+		panic(err)
+	}
+}
+
 // GetProvider returns a new provider for the given location.
 func (r *Registry) GetPlugin(location string) (zipspy.Reader, error) {
 	r.providersMutex.RLock()
 	defer r.providersMutex.RUnlock()
 	for _, provider := range r.providers {
-		if strings.HasPrefix(location, provider.Protocol) {
+		if provider.Match != nil && provider.Match(location) {
+			return provider.CreatePlugin(location)
+		}
+	}
+	for _, provider := range r.providers {
+		if provider.Match == nil && strings.HasPrefix(location, provider.Protocol) {
 			return provider.CreatePlugin(strings.TrimPrefix(location, provider.Protocol))
 		}
 	}
@@ -0,0 +1,317 @@
+package sftp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alec-rabold/zipspy/pkg/zipspy"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+var _ zipspy.Reader = (*Client)(nil)
+var _ zipspy.Versioner = (*Client)(nil)
+
+// defaultPort is used for locations without a port.
+const defaultPort = "22"
+
+// dialTimeout bounds the time to establish the TCP connection to the server.
+const dialTimeout = 30 * time.Second
+
+// ErrClosed is returned by reads after the client is closed.
+var ErrClosed = errors.New("sftp client is closed")
+
+// defaultKeyFiles are the private keys tried, relative to ~/.ssh, when no key file is given.
+var defaultKeyFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// Client implements the zipspy.Reader interface over random-access SFTP reads.
+// A single SSH connection is opened on first use and shared by all reads, which may run concurrently.
+type Client struct {
+	addr      string
+	path      string
+	config    *ssh.ClientConfig
+	signers   []ssh.Signer
+	password  string
+	agentSock string
+
+	connectOnce sync.Once
+	conn        *ssh.Client
+	sftp        *sftp.Client
+	file        *sftp.File
+	info        os.FileInfo
+	connectErr  error
+}
+
+// Option configures an SFTP client.
+type Option func(*options)
+
+type options struct {
+	keyFiles        []string
+	password        string
+	knownHostsFiles []string
+	ignoreHostKey   bool
+	noAgent         bool
+}
+
+// WithKeyFile authenticates with the private key in the given file. It may be repeated.
+// Passphrase-protected keys must be loaded into ssh-agent instead.
+func WithKeyFile(path string) Option {
+	return func(o *options) {
+		o.keyFiles = append(o.keyFiles, path)
+	}
+}
+
+// WithPassword authenticates with a password, which the user info of the location takes precedence over.
+func WithPassword(password string) Option {
+	return func(o *options) {
+		o.password = password
+	}
+}
+
+// WithKnownHostsFile verifies the server's host key against the given known_hosts file instead of
+// ~/.ssh/known_hosts. It may be repeated.
+func WithKnownHostsFile(path string) Option {
+	return func(o *options) {
+		o.knownHostsFiles = append(o.knownHostsFiles, path)
+	}
+}
+
+// WithInsecureIgnoreHostKey accepts any host key, which makes the connection vulnerable to
+// man-in-the-middle attacks. It should only be used for testing.
+func WithInsecureIgnoreHostKey() Option {
+	return func(o *options) {
+		o.ignoreHostKey = true
+	}
+}
+
+// WithoutAgent doesn't use the keys of the ssh-agent found through $SSH_AUTH_SOCK.
+func WithoutAgent() Option {
+	return func(o *options) {
+		o.noAgent = true
+	}
+}
+
+// NewCreator returns a provider constructor for "sftp://" locations using the given options.
+// The provider registry strips the protocol from the location, so it is added back here.
+func NewCreator(opts ...Option) func(location string) (zipspy.Reader, error) {
+	return func(location string) (zipspy.Reader, error) {
+		return NewClient("sftp://"+location, opts...)
+	}
+}
+
+// NewClient creates a new SFTP file reader for a location of the form "sftp://[user[:password]@]host[:port]/path".
+// The path is absolute, unless it starts with "/~/" to be relative to the user's home directory.
+// The user defaults to $USER.
+//
+// Clients authenticate with the keys of ssh-agent ($SSH_AUTH_SOCK), the given key files (or the default keys in ~/.ssh)
+// and the password, and verify the server's host key against ~/.ssh/known_hosts unless configured otherwise.
+func NewClient(location string, opts ...Option) (*Client, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	u, err := url.Parse(location)
+	if err != nil || u.Scheme != "sftp" || u.Host == "" || u.Path == "" || u.Path == "/" {
+		return nil, fmt.Errorf("invalid SFTP location, expected sftp://[user@]host[:port]/path (location: %s)", location)
+	}
+	path := u.Path
+	if strings.HasPrefix(path, "/~/") {
+		path = strings.TrimPrefix(path, "/~/")
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+	user := u.User.Username()
+	if user == "" {
+		user = os.Getenv("USER")
+	}
+	if password, ok := u.User.Password(); ok {
+		o.password = password
+	}
+
+	signers, err := loadKeys(&o)
+	if err != nil {
+		return nil, err
+	}
+	hostKeyCallback, err := hostKeyCallback(&o)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		addr: addr,
+		path: path,
+		config: &ssh.ClientConfig{
+			User:            user,
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         dialTimeout,
+		},
+		signers:  signers,
+		password: o.password,
+	}
+	if !o.noAgent {
+		c.agentSock = os.Getenv("SSH_AUTH_SOCK")
+	}
+	return c, nil
+}
+
+// Size returns the size of the file.
+func (c *Client) Size() (int64, error) {
+	if err := c.connect(); err != nil {
+		return 0, err
+	}
+	return c.info.Size(), nil
+}
+
+// Version returns the modification time and size of the file.
+func (c *Client) Version() (string, error) {
+	if err := c.connect(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d-%d", c.info.ModTime().UnixNano(), c.info.Size()), nil
+}
+
+// ReadAt implements the io.ReaderAt interface by reading a byte range of the file over the shared connection.
+func (c *Client) ReadAt(p []byte, off int64) (n int, err error) {
+	if err := c.connect(); err != nil {
+		return 0, err
+	}
+	return c.file.ReadAt(p, off)
+}
+
+// Close closes the file and the connection to the server. Reads fail with ErrClosed afterwards.
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	c.file.Close()
+	// Closing the connection first ends the SFTP session, which sftp.Close waits for.
+	err := c.conn.Close()
+	c.sftp.Close()
+	c.conn, c.sftp, c.file = nil, nil, nil
+	c.connectErr = ErrClosed
+	return err
+}
+
+// connect opens the connection and the file once, and reuses them for subsequent calls.
+func (c *Client) connect() error {
+	c.connectOnce.Do(func() {
+		signers := c.signers
+		if c.agentSock != "" {
+			// The agent is only needed to sign the handshake, the other keys are tried if it's unavailable.
+			if agentConn, err := net.Dial("unix", c.agentSock); err == nil {
+				defer agentConn.Close()
+				if fromAgent, err := agent.NewClient(agentConn).Signers(); err == nil {
+					signers = append(fromAgent, signers...)
+				}
+			}
+		}
+		config := *c.config
+		if len(signers) > 0 {
+			config.Auth = append(config.Auth, ssh.PublicKeys(signers...))
+		}
+		if c.password != "" {
+			config.Auth = append(config.Auth, ssh.Password(c.password))
+		}
+		conn, err := ssh.Dial("tcp", c.addr, &config)
+		if err != nil {
+			c.connectErr = fmt.Errorf("failed to connect (host: %s) (user: %s): %w", c.addr, c.config.User, err)
+			return
+		}
+		client, err := sftp.NewClient(conn)
+		if err != nil {
+			conn.Close()
+			c.connectErr = fmt.Errorf("failed to start SFTP session (host: %s): %w", c.addr, err)
+			return
+		}
+		file, err := client.Open(c.path)
+		if err != nil {
+			// Closing the connection first ends the SFTP session, which client.Close waits for.
+			conn.Close()
+			client.Close()
+			c.connectErr = fmt.Errorf("failed to open file (host: %s) (path: %s): %w", c.addr, c.path, err)
+			return
+		}
+		info, err := file.Stat()
+		if err != nil {
+			conn.Close()
+			client.Close()
+			c.connectErr = fmt.Errorf("failed to stat file (host: %s) (path: %s): %w", c.addr, c.path, err)
+			return
+		}
+		c.conn, c.sftp, c.file, c.info = conn, client, file, info
+	})
+	return c.connectErr
+}
+
+// loadKeys reads the key files, or the default keys in ~/.ssh that exist and aren't protected by a passphrase.
+func loadKeys(o *options) ([]ssh.Signer, error) {
+	var signers []ssh.Signer
+	keyFiles, explicit := o.keyFiles, len(o.keyFiles) > 0
+	if !explicit {
+		if home, err := os.UserHomeDir(); err == nil {
+			for _, name := range defaultKeyFiles {
+				keyFiles = append(keyFiles, filepath.Join(home, ".ssh", name))
+			}
+		}
+	}
+	for _, path := range keyFiles {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			if !explicit && errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read private key (path: %s): %w", path, err)
+		}
+		signer, err := ssh.ParsePrivateKey(b)
+		var passphraseErr *ssh.PassphraseMissingError
+		switch {
+		case errors.As(err, &passphraseErr) && !explicit:
+			// ssh-agent is expected to hold passphrase-protected default keys.
+			continue
+		case errors.As(err, &passphraseErr):
+			return nil, fmt.Errorf("private key is protected by a passphrase, add it to ssh-agent instead (path: %s)", path)
+		case err != nil:
+			return nil, fmt.Errorf("failed to parse private key (path: %s): %w", path, err)
+		}
+		signers = append(signers, signer)
+	}
+	return signers, nil
+}
+
+// hostKeyCallback verifies host keys against the known_hosts files, explaining how to add unknown hosts.
+func hostKeyCallback(o *options) (ssh.HostKeyCallback, error) {
+	if o.ignoreHostKey {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	files := o.knownHostsFiles
+	if len(files) == 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find known_hosts file: %w", err)
+		}
+		files = []string{filepath.Join(home, ".ssh", "known_hosts")}
+	}
+	callback, err := knownhosts.New(files...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read known_hosts file: %w", err)
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := callback(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+			return fmt.Errorf("host key not found in known_hosts, add it with ssh-keyscan (host: %s) (key: %s %s)",
+				hostname, key.Type(), ssh.FingerprintSHA256(key))
+		}
+		return err
+	}, nil
+}
@@ -0,0 +1,250 @@
+package sftp
+
+import (
+	"archive/zip"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alec-rabold/zipspy/pkg/zipspy"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	testUser     = "alice"
+	testPassword = "secret"
+)
+
+// newKey returns a new ECDSA key, and its PEM encoding for key files.
+func newKey(t *testing.T) (ssh.Signer, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+// testServer is an in-process SSH server with an SFTP subsystem serving the local file system.
+type testServer struct {
+	addr    string
+	hostKey ssh.Signer
+	closed  chan struct{} // receives a value whenever a connection ends
+}
+
+func newTestServer(t *testing.T, authorizedKey ssh.PublicKey) *testServer {
+	t.Helper()
+	hostKey, _ := newKey(t)
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == testUser && string(password) == testPassword {
+				return nil, nil
+			}
+			return nil, errors.New("wrong password")
+		},
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == testUser && string(key.Marshal()) == string(authorizedKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unknown key")
+		},
+	}
+	config.AddHostKey(hostKey)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	s := &testServer{addr: l.Addr().String(), hostKey: hostKey, closed: make(chan struct{}, 10)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, config)
+		}
+	}()
+	return s
+}
+
+func (s *testServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	defer func() { s.closed <- struct{}{} }()
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			break
+		}
+		go func() {
+			for req := range requests {
+				// The payload of a subsystem request is the length-prefixed subsystem name.
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if ok {
+					go func() {
+						if server, err := sftp.NewServer(channel, sftp.ReadOnly()); err == nil {
+							server.Serve()
+							server.Close()
+						}
+					}()
+				}
+			}
+		}()
+	}
+	sconn.Wait()
+}
+
+// knownHosts writes a known_hosts file with the given key for the server's address.
+func (s *testServer) knownHosts(t *testing.T, key ssh.PublicKey) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(s.addr)}, key) + "\n"
+	if err := os.WriteFile(path, []byte(line), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeArchive writes an archive holding a single file and returns its path.
+func writeArchive(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "archive.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	w, err := zw.Create("notes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("Notes from file."))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestAuthentication(t *testing.T) {
+	clientKey, clientKeyPEM := newKey(t)
+	keyFile := filepath.Join(t.TempDir(), "id_ecdsa")
+	if err := os.WriteFile(keyFile, clientKeyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	otherKey, otherKeyPEM := newKey(t)
+	otherKeyFile := filepath.Join(t.TempDir(), "id_other")
+	if err := os.WriteFile(otherKeyFile, otherKeyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// Default keys and known_hosts files in ~/.ssh aren't used.
+	t.Setenv("HOME", t.TempDir())
+	s := newTestServer(t, clientKey.PublicKey())
+	knownHosts := s.knownHosts(t, s.hostKey.PublicKey())
+	emptyKnownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(emptyKnownHosts, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	archive := writeArchive(t)
+
+	tests := []struct {
+		name       string
+		userinfo   string
+		knownHosts string // defaults to a file with the server's key
+		opts       []Option
+		wantErr    string
+	}{
+		{name: "key", userinfo: testUser, opts: []Option{WithKeyFile(keyFile)}},
+		{name: "password option", userinfo: testUser, opts: []Option{WithPassword(testPassword)}},
+		{name: "password in location", userinfo: testUser + ":" + testPassword},
+		{name: "key and password", userinfo: testUser, opts: []Option{WithKeyFile(otherKeyFile), WithPassword(testPassword)}},
+		{name: "wrong key", userinfo: testUser, opts: []Option{WithKeyFile(otherKeyFile)}, wantErr: "unable to authenticate"},
+		{name: "wrong password", userinfo: testUser + ":guess", wantErr: "unable to authenticate"},
+		{
+			name:       "changed host key",
+			userinfo:   testUser + ":" + testPassword,
+			knownHosts: s.knownHosts(t, otherKey.PublicKey()),
+			wantErr:    "key mismatch",
+		},
+		{
+			name:       "unknown host",
+			userinfo:   testUser + ":" + testPassword,
+			knownHosts: emptyKnownHosts,
+			wantErr:    "host key not found in known_hosts",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.knownHosts == "" {
+				tt.knownHosts = knownHosts
+			}
+			opts := append([]Option{WithoutAgent(), WithKnownHostsFile(tt.knownHosts)}, tt.opts...)
+			c, err := NewClient("sftp://"+tt.userinfo+"@"+s.addr+archive, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			zc, err := zipspy.NewClient(c)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("NewClient() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			rc, err := zc.GetFiles([]string{"notes.txt"})[0].Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil || string(b) != "Notes from file." {
+				t.Errorf("notes.txt = %q, %v, want %q", b, err, "Notes from file.")
+			}
+
+			// Closing the zipspy client closes the connection.
+			if err := zc.Close(); err != nil {
+				t.Errorf("Close() = %v", err)
+			}
+			select {
+			case <-s.closed:
+			case <-time.After(5 * time.Second):
+				t.Fatal("connection still open after Close")
+			}
+			if _, err := c.ReadAt(make([]byte, 1), 0); !errors.Is(err, ErrClosed) {
+				t.Errorf("ReadAt() after Close: error = %v, want %v", err, ErrClosed)
+			}
+		})
+	}
+}
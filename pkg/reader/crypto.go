@@ -0,0 +1,256 @@
+package reader
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+var (
+	// ErrPassword is returned when opening an encrypted file without a password, or with the wrong one.
+	ErrPassword = errors.New("zip: invalid or missing password")
+	// ErrAuthentication is returned when the authentication code of an AES encrypted file doesn't match its data.
+	ErrAuthentication = errors.New("zip: authentication failed")
+)
+
+const (
+	zipCryptoHeaderLen = 12
+	aesVerifierLen     = 2
+	aesAuthCodeLen     = 10
+	aesIterations      = 1000
+)
+
+// aesExtra is the WinZip AES extra field of an encrypted file.
+type aesExtra struct {
+	Version  uint16 // 1 for AE-1, 2 for AE-2 (which omits the CRC-32)
+	Strength uint8  // 1, 2 or 3 for AES-128, AES-192 or AES-256
+	Method   uint16 // compression method of the decrypted data
+}
+
+// keyLen returns the length in bytes of the AES key.
+func (e *aesExtra) keyLen() int {
+	switch e.Strength {
+	case 1:
+		return 16
+	case 2:
+		return 24
+	case 3:
+		return 32
+	default:
+		return 0
+	}
+}
+
+// isEncrypted reports whether the file's data is encrypted.
+func (f *File) isEncrypted() bool {
+	return f.Flags&0x1 != 0
+}
+
+// isAE2 reports whether the file is encrypted with AE-2, whose CRC-32 is not stored.
+func (f *File) isAE2() bool {
+	return f.aes != nil && f.aes.Version == 2
+}
+
+// CompressionMethod returns the method the file's data is compressed with.
+// AES encrypted files record it in their extra field, their Method being AES.
+func (f *File) CompressionMethod() uint16 {
+	if f.aes != nil {
+		return f.aes.Method
+	}
+	return f.Method
+}
+
+// Encryption returns the name of the method the file's data is encrypted with
+// ("zipcrypto", "aes-128", "aes-192" or "aes-256", or "aes" for an unknown key length),
+// or "" if it isn't encrypted.
+func (f *File) Encryption() string {
+	switch {
+	case !f.isEncrypted():
+		return ""
+	case f.aes != nil && f.aes.keyLen() > 0:
+		return fmt.Sprintf("aes-%d", f.aes.keyLen()*8)
+	case f.aes != nil || f.Method == AES:
+		return "aes"
+	default:
+		return "zipcrypto"
+	}
+}
+
+// decrypt returns a reader of the decrypted data of a file, given its raw data.
+// It returns ErrPassword if the password doesn't match.
+func (f *File) decrypt(r io.Reader) (io.Reader, error) {
+	password := f.zip.password
+	if password == nil {
+		return nil, ErrPassword
+	}
+	if f.aes != nil {
+		return newAESReader(r, password, f.aes, int64(f.CompressedSize64))
+	}
+	return newZipCryptoReader(r, password, f.zipCryptoCheck())
+}
+
+// zipCryptoCheck returns the byte the last byte of the decrypted header must match.
+// When the file has a data descriptor its CRC-32 may not be known when the header
+// is written, so the high byte of the modification time is used instead.
+func (f *File) zipCryptoCheck() byte {
+	if f.hasDataDescriptor() {
+		return byte(f.ModifiedTime >> 8)
+	}
+	return byte(f.CRC32 >> 24)
+}
+
+// zipCryptoKeys is the state of the traditional PKWARE encryption,
+// described in section 6.1 of the APPNOTE.
+type zipCryptoKeys [3]uint32
+
+func newZipCryptoKeys(password []byte) *zipCryptoKeys {
+	k := &zipCryptoKeys{0x12345678, 0x23456789, 0x34567890}
+	for _, b := range password {
+		k.update(b)
+	}
+	return k
+}
+
+func crc32Update(crc uint32, b byte) uint32 {
+	return crc32.IEEETable[byte(crc)^b] ^ crc>>8
+}
+
+func (k *zipCryptoKeys) update(b byte) {
+	k[0] = crc32Update(k[0], b)
+	k[1] = (k[1]+k[0]&0xff)*134775813 + 1
+	k[2] = crc32Update(k[2], byte(k[1]>>24))
+}
+
+func (k *zipCryptoKeys) decryptByte(b byte) byte {
+	t := k[2] | 2
+	b ^= byte(t * (t ^ 1) >> 8)
+	k.update(b)
+	return b
+}
+
+type zipCryptoReader struct {
+	r    io.Reader
+	keys *zipCryptoKeys
+}
+
+// newZipCryptoReader reads and checks the encryption header, then returns a reader of the decrypted data.
+func newZipCryptoReader(r io.Reader, password []byte, check byte) (io.Reader, error) {
+	var header [zipCryptoHeaderLen]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	keys := newZipCryptoKeys(password)
+	for i := range header {
+		header[i] = keys.decryptByte(header[i])
+	}
+	if header[zipCryptoHeaderLen-1] != check {
+		return nil, ErrPassword
+	}
+	return &zipCryptoReader{r: r, keys: keys}, nil
+}
+
+func (z *zipCryptoReader) Read(p []byte) (int, error) {
+	n, err := z.r.Read(p)
+	for i := 0; i < n; i++ {
+		p[i] = z.keys.decryptByte(p[i])
+	}
+	return n, err
+}
+
+// aesReader decrypts WinZip AES encrypted data, described in https://www.winzip.com/en/support/aes-encryption/.
+// The data is encrypted with AES in CTR mode, using a little-endian counter starting at 1,
+// and authenticated with HMAC-SHA1 over the encrypted data.
+type aesReader struct {
+	r        io.Reader // encrypted data
+	authCode io.Reader // authentication code following the data
+	block    cipher.Block
+	mac      hash.Hash
+	counter  uint64
+	stream   [aes.BlockSize]byte
+	pos      int // position in stream, aes.BlockSize when a new block is needed
+}
+
+// newAESReader reads the salt and password verifier, derives the keys and returns a reader of the decrypted data.
+// size is the size of the raw data including the salt, verifier and authentication code.
+func newAESReader(r io.Reader, password []byte, e *aesExtra, size int64) (io.Reader, error) {
+	keyLen := e.keyLen()
+	if keyLen == 0 {
+		return nil, ErrAlgorithm
+	}
+	saltLen := keyLen / 2
+	dataLen := size - int64(saltLen+aesVerifierLen+aesAuthCodeLen)
+	if dataLen < 0 {
+		return nil, ErrFormat
+	}
+	header := make([]byte, saltLen+aesVerifierLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	salt, verifier := header[:saltLen], header[saltLen:]
+	keys := pbkdf2.Key(password, salt, aesIterations, 2*keyLen+aesVerifierLen, sha1.New)
+	if !hmac.Equal(keys[2*keyLen:], verifier) {
+		return nil, ErrPassword
+	}
+	block, err := aes.NewCipher(keys[:keyLen])
+	if err != nil {
+		return nil, err
+	}
+	return &aesReader{
+		r:        io.LimitReader(r, dataLen),
+		authCode: r,
+		block:    block,
+		mac:      hmac.New(sha1.New, keys[keyLen:2*keyLen]),
+		pos:      aes.BlockSize,
+	}, nil
+}
+
+func (a *aesReader) Read(p []byte) (int, error) {
+	n, err := a.r.Read(p)
+	a.mac.Write(p[:n])
+	for i := 0; i < n; i++ {
+		if a.pos == aes.BlockSize {
+			a.counter++
+			var ctr [aes.BlockSize]byte
+			binary.LittleEndian.PutUint64(ctr[:], a.counter)
+			a.block.Encrypt(a.stream[:], ctr[:])
+			a.pos = 0
+		}
+		p[i] ^= a.stream[a.pos]
+		a.pos++
+	}
+	return n, err
+}
+
+// verify checks the authentication code once the data has been decompressed.
+// Any data the decompressor didn't need is still authenticated.
+func (a *aesReader) verify() error {
+	if _, err := io.Copy(a.mac, a.r); err != nil {
+		return err
+	}
+	var code [aesAuthCodeLen]byte
+	if _, err := io.ReadFull(a.authCode, code[:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if !hmac.Equal(a.mac.Sum(nil)[:aesAuthCodeLen], code[:]) {
+		return ErrAuthentication
+	}
+	return nil
+}
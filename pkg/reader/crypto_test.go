@@ -0,0 +1,182 @@
+package reader
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestZipCrypto(t *testing.T) {
+	tests := []struct {
+		file           string
+		dataDescriptor bool // the check byte is the high byte of the modification time, not of the CRC-32
+	}{
+		{"zipcrypto.zip", true},
+		{"zipcrypto-crc.zip", false},
+	}
+	want := testContent()
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			rc, err := OpenReader(filepath.Join("testdata", tt.file), WithPassword("secret"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rc.Close()
+			for _, f := range rc.File {
+				if got := f.hasDataDescriptor(); got != tt.dataDescriptor {
+					t.Errorf("%s: hasDataDescriptor() = %v, want %v", f.Name, got, tt.dataDescriptor)
+				}
+				if got := f.Encryption(); got != "zipcrypto" {
+					t.Errorf("%s: Encryption() = %q, want %q", f.Name, got, "zipcrypto")
+				}
+			}
+			checkEntries(t, &rc.Reader, Deflate, want)
+		})
+	}
+}
+
+func TestZipCryptoWrongPassword(t *testing.T) {
+	passwords := map[string][]Option{
+		"wrong password":   {WithPassword("guess")},
+		"missing password": nil,
+	}
+	for _, file := range []string{"zipcrypto.zip", "zipcrypto-crc.zip"} {
+		for name, opts := range passwords {
+			rc, err := OpenReader(filepath.Join("testdata", file), opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range rc.File {
+				if _, err := f.Open(); !errors.Is(err, ErrPassword) {
+					t.Errorf("%s: %s with a %s: Open() error = %v, want %v", file, f.Name, name, err, ErrPassword)
+				}
+			}
+			rc.Close()
+		}
+	}
+}
+
+// aesContent returns the contents of the entries of aes.zip, each file of testContent being in
+// a directory per encryption.
+func aesContent() map[string]string {
+	want := make(map[string]string)
+	for _, dir := range []string{"ae1-aes128", "ae2-aes128", "ae1-aes256", "ae2-aes256"} {
+		for name, content := range testContent() {
+			want[dir+"/"+name] = content
+		}
+	}
+	return want
+}
+
+// openAES reads aes.zip, after changing its bytes with tamper if not nil.
+func openAES(t *testing.T, password string, tamper func(zr *Reader, b []byte)) *Reader {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", "aes.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := NewReader(bytes.NewReader(b), int64(len(b)), WithPassword(password))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tamper != nil {
+		tamper(zr, b)
+	}
+	return zr
+}
+
+func TestAES(t *testing.T) {
+	zr := openAES(t, "secret", nil)
+	for _, f := range zr.File {
+		dir := path.Dir(f.Name)
+		if want := "aes-" + dir[len(dir)-3:]; f.Encryption() != want {
+			t.Errorf("%s: Encryption() = %q, want %q", f.Name, f.Encryption(), want)
+		}
+		if got, want := f.isAE2(), strings.HasPrefix(dir, "ae2"); got != want {
+			t.Errorf("%s: isAE2() = %v, want %v", f.Name, got, want)
+		}
+		want := Store
+		if path.Base(f.Name) == "lorem.txt" {
+			want = Deflate
+		}
+		if got := f.CompressionMethod(); got != want {
+			t.Errorf("%s: CompressionMethod() = %d, want %d", f.Name, got, want)
+		}
+	}
+	checkEntries(t, zr, AES, aesContent())
+}
+
+func TestAESWrongPassword(t *testing.T) {
+	for _, password := range []string{"guess", ""} {
+		zr := openAES(t, password, nil)
+		for _, f := range zr.File {
+			// The password verifier is checked when opening the file.
+			if _, err := f.Open(); !errors.Is(err, ErrPassword) {
+				t.Errorf("%s with password %q: Open() error = %v, want %v", f.Name, password, err, ErrPassword)
+			}
+		}
+	}
+}
+
+// readEntry reads the contents of the file, returning the first error.
+func readEntry(f *File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	_, err = io.Copy(io.Discard, rc)
+	return err
+}
+
+func TestAESAuthentication(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset func(f *File) int64 // offset of the byte to change, from the start of the file's data
+		only   string              // base name of the files checked, all if empty
+	}{
+		// Stored data decrypts without errors, so that only the authentication code can catch the change.
+		{"ciphertext", func(f *File) int64 { return int64(f.aes.keyLen()/2 + aesVerifierLen) }, "small.txt"},
+		{"authentication code", func(f *File) int64 { return int64(f.CompressedSize64) - 1 }, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zr := openAES(t, "secret", func(zr *Reader, b []byte) {
+				for _, f := range zr.File {
+					off, err := f.DataOffset()
+					if err != nil {
+						t.Fatal(err)
+					}
+					b[off+tt.offset(f)] ^= 0x80
+				}
+			})
+			for _, f := range zr.File {
+				if tt.only != "" && path.Base(f.Name) != tt.only {
+					continue
+				}
+				if err := readEntry(f); !errors.Is(err, ErrAuthentication) {
+					t.Errorf("%s: error = %v, want %v", f.Name, err, ErrAuthentication)
+				}
+			}
+		})
+	}
+}
+
+func TestAE2SkipsCRC(t *testing.T) {
+	zr := openAES(t, "secret", nil)
+	for _, f := range zr.File {
+		f.CRC32 = 0xdeadbeef
+		want := ErrChecksum
+		if f.isAE2() {
+			want = nil
+		}
+		if err := readEntry(f); !errors.Is(err, want) {
+			t.Errorf("%s with a wrong CRC-32: error = %v, want %v", f.Name, err, want)
+		}
+	}
+}
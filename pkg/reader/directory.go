@@ -0,0 +1,89 @@
+package reader
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+)
+
+// DirectoryFormat identifies the encoding produced by MarshalDirectory.
+// It changes whenever the encoding does, so stale cached directories can be discarded.
+const DirectoryFormat = "zipspy-directory-v3"
+
+// directory is the serialized form of a Reader's central directory.
+type directory struct {
+	Comment string
+	Size    uint64 // size of the central directory in the archive, checked against Limits.MaxDirectorySize
+	Files   []directoryRecord
+}
+
+// directoryRecord is the serialized form of a File.
+type directoryRecord struct {
+	Header       FileHeader
+	HeaderOffset int64
+	Zip64        bool
+	AES          *aesExtra
+}
+
+// MarshalDirectory encodes the parsed central directory of the archive
+// so that it can later be restored with NewReaderFromDirectory
+// without reading the directory from the archive again.
+func (z *Reader) MarshalDirectory() ([]byte, error) {
+	d := directory{
+		Comment: z.Comment,
+		Size:    z.directorySize,
+		Files:   make([]directoryRecord, 0, len(z.File)),
+	}
+	for _, f := range z.File {
+		if f.descErr != nil {
+			// Don't persist archives whose data descriptors couldn't be read,
+			// the error should surface again the next time the archive is opened.
+			return nil, fmt.Errorf("failed to read data descriptor (name: %s): %w", f.Name, f.descErr)
+		}
+		d.Files = append(d.Files, directoryRecord{
+			Header:       f.FileHeader,
+			HeaderOffset: f.headerOffset,
+			Zip64:        f.zip64,
+			AES:          f.aes,
+		})
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(d); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// NewReaderFromDirectory returns a new Reader reading from r
+// using a central directory previously encoded by MarshalDirectory.
+func NewReaderFromDirectory(r io.ReaderAt, b []byte, opts ...Option) (*Reader, error) {
+	var d directory
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&d); err != nil {
+		return nil, fmt.Errorf("failed to decode directory: %w", err)
+	}
+	z := &Reader{
+		r:             r,
+		Comment:       d.Comment,
+		File:          make([]*File, 0, len(d.Files)),
+		directorySize: d.Size,
+	}
+	for _, opt := range opts {
+		opt(z)
+	}
+	// The same limits apply as when reading the directory from the archive.
+	if err := z.limits.checkDirectory(d.Size, uint64(len(d.Files))); err != nil {
+		return nil, err
+	}
+	for _, rec := range d.Files {
+		z.File = append(z.File, &File{
+			FileHeader:   rec.Header,
+			zip:          z,
+			zipr:         r,
+			headerOffset: rec.HeaderOffset,
+			zip64:        rec.Zip64,
+			aes:          rec.AES,
+		})
+	}
+	return z, nil
+}
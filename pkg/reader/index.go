@@ -0,0 +1,277 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+	"sync"
+)
+
+// IndexFormat identifies the encoding produced by Index.Marshal.
+// It changes whenever the encoding does, so stale cached indexes can be discarded.
+const IndexFormat = "zipspy-index-v1"
+
+// DefaultIndexSpan is the default distance between checkpoints, in uncompressed bytes.
+const DefaultIndexSpan = 4 << 20 // 4 MiB
+
+// seekBufferSize is the size of each read of compressed data when decompressing from a checkpoint.
+const seekBufferSize = 256 << 10 // 256 KiB
+
+// ErrIndex is returned when an index doesn't match the file it is used with.
+var ErrIndex = errors.New("zip: index does not match file")
+
+// An Index records checkpoints in the compressed data of a file, from which
+// decompression can be resumed. It allows random access to compressed files
+// without decompressing them from the beginning, see File.OpenSeekable.
+type Index struct {
+	Span             int64 // minimum distance between checkpoints, in uncompressed bytes
+	CompressedSize   uint64
+	UncompressedSize uint64
+	CRC32            uint32
+	Checkpoints      []Checkpoint // ordered by offset, the first is always at the start of the file
+}
+
+// A Checkpoint is a position at the start of a compressed block.
+type Checkpoint struct {
+	In     int64  // offset in the compressed data, in bits
+	Out    int64  // offset in the uncompressed data, in bytes
+	Window []byte // uncompressed data preceding Out that later blocks may refer back to
+}
+
+// BuildIndex decompresses the file once, recording a checkpoint roughly every span bytes
+// of uncompressed data. The file's checksum is verified while doing so.
+// Only Deflate and Deflate64 compressed files can be indexed, stored files don't need an index.
+func (f *File) BuildIndex(span int64) (*Index, error) {
+	if span <= 0 {
+		span = DefaultIndexSpan
+	}
+	deflate64, err := f.indexable()
+	if err != nil {
+		return nil, err
+	}
+	data, err := f.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &Index{
+		Span:             span,
+		CompressedSize:   f.CompressedSize64,
+		UncompressedSize: f.UncompressedSize64,
+		CRC32:            f.CRC32,
+		Checkpoints:      []Checkpoint{{}},
+	}
+	fr := newInflater(bufio.NewReaderSize(data, seekBufferSize), deflate64, nil)
+	fr.onBlock = func(fr *inflater) {
+		if fr.out-idx.Checkpoints[len(idx.Checkpoints)-1].Out >= span {
+			idx.Checkpoints = append(idx.Checkpoints, Checkpoint{
+				In:     fr.bitOffset(),
+				Out:    fr.out,
+				Window: fr.window(),
+			})
+		}
+	}
+	rc := &checksumReader{
+		rc:   fr,
+		hash: crc32.NewIEEE(),
+		f:    f,
+	}
+	if _, err := io.Copy(io.Discard, rc); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// indexable reports whether the file's data can be indexed,
+// and if so whether it uses Deflate64 rather than Deflate.
+func (f *File) indexable() (deflate64 bool, err error) {
+	if f.Flags&0x1 != 0 {
+		return false, fmt.Errorf("zip: encrypted files can't be indexed")
+	}
+	switch f.Method {
+	case Deflate:
+		return false, nil
+	case Deflate64:
+		return true, nil
+	default:
+		return false, ErrAlgorithm
+	}
+}
+
+// Marshal encodes the index so that it can be persisted and later restored with UnmarshalIndex.
+func (idx *Index) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	// Windows are mostly plain text in practice, so compress them.
+	w, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if err := gob.NewEncoder(w).Encode(idx); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalIndex decodes an index encoded by Index.Marshal.
+func UnmarshalIndex(b []byte) (*Index, error) {
+	var idx Index
+	r := flate.NewReader(bytes.NewReader(b))
+	defer r.Close()
+	if err := gob.NewDecoder(r).Decode(&idx); err != nil {
+		return nil, fmt.Errorf("failed to decode index: %w", err)
+	}
+	if len(idx.Checkpoints) == 0 || idx.Checkpoints[0].Out != 0 {
+		return nil, fmt.Errorf("failed to decode index: %w", ErrIndex)
+	}
+	return &idx, nil
+}
+
+// checkpoint returns the last checkpoint at or before the uncompressed offset.
+func (idx *Index) checkpoint(off int64) *Checkpoint {
+	i := sort.Search(len(idx.Checkpoints), func(i int) bool {
+		return idx.Checkpoints[i].Out > off
+	})
+	return &idx.Checkpoints[i-1]
+}
+
+// SeekableReader provides random access to the contents of a file.
+// Checksums are not verified, since the file is generally not read in full.
+type SeekableReader struct {
+	f         *File
+	idx       *Index // nil for stored files
+	data      *io.SectionReader
+	deflate64 bool
+	off       int64 // offset for Read and Seek
+
+	mu        sync.Mutex // guards the fields below
+	stream    *inflater  // decompressor left positioned at streamOff by the last read
+	streamOff int64
+}
+
+var (
+	_ io.ReadSeeker = (*SeekableReader)(nil)
+	_ io.ReaderAt   = (*SeekableReader)(nil)
+)
+
+// OpenSeekable returns a reader providing random access to the File's contents.
+// Stored files don't need an index, compressed files need an index built by BuildIndex.
+// Each read only fetches the compressed data following the nearest checkpoint.
+func (f *File) OpenSeekable(idx *Index) (*SeekableReader, error) {
+	raw, err := f.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+	data, ok := raw.(*io.SectionReader)
+	if !ok {
+		return nil, ErrAlgorithm
+	}
+	r := &SeekableReader{f: f, data: data}
+	if f.Method == Store && f.Flags&0x1 == 0 {
+		return r, nil
+	}
+	if r.deflate64, err = f.indexable(); err != nil {
+		return nil, err
+	}
+	if idx == nil {
+		return nil, fmt.Errorf("zip: an index is required for compressed files")
+	}
+	if idx.CompressedSize != f.CompressedSize64 || idx.UncompressedSize != f.UncompressedSize64 || idx.CRC32 != f.CRC32 {
+		return nil, ErrIndex
+	}
+	r.idx = idx
+	return r, nil
+}
+
+// Size returns the uncompressed size of the file.
+func (r *SeekableReader) Size() int64 {
+	return int64(r.f.UncompressedSize64)
+}
+
+func (r *SeekableReader) Read(p []byte) (int, error) {
+	n, err := r.ReadAt(p, r.off)
+	r.off += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (r *SeekableReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.off
+	case io.SeekEnd:
+		offset += r.Size()
+	default:
+		return 0, errors.New("zip: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("zip: negative position")
+	}
+	r.off = offset
+	return offset, nil
+}
+
+// ReadAt reads len(p) bytes of uncompressed data starting at off.
+// It may be called concurrently, but reads of compressed files are serialized.
+func (r *SeekableReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("zip: negative offset")
+	}
+	if off >= r.Size() {
+		return 0, io.EOF
+	}
+	if r.idx == nil {
+		return r.data.ReadAt(p, off)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.seekStream(off); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(r.stream, p)
+	r.streamOff += int64(n)
+	if err == io.ErrUnexpectedEOF && r.streamOff == r.Size() {
+		err = io.EOF
+	}
+	if err != nil && err != io.EOF {
+		r.stream = nil
+	}
+	return n, err
+}
+
+// seekStream positions the decompressor at off, reusing the current one
+// if no checkpoint lies between its position and off.
+func (r *SeekableReader) seekStream(off int64) error {
+	cp := r.idx.checkpoint(off)
+	if r.stream == nil || r.streamOff > off || r.streamOff < cp.Out {
+		start := cp.In / 8
+		data := io.NewSectionReader(r.data, start, r.data.Size()-start)
+		stream := newInflater(bufio.NewReaderSize(data, seekBufferSize), r.deflate64, cp.Window)
+		if err := stream.skipBits(uint(cp.In % 8)); err != nil {
+			return err
+		}
+		r.stream, r.streamOff = stream, cp.Out
+	}
+	n, err := io.CopyN(io.Discard, r.stream, off-r.streamOff)
+	r.streamOff += n
+	if err != nil {
+		r.stream = nil
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return nil
+}
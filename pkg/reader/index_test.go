@@ -0,0 +1,145 @@
+package reader
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+// newDeflateArchive returns an archive of a single file compressed by compress/flate.
+func newDeflateArchive(t *testing.T, data []byte) *Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	zw.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(w, flate.BestCompression)
+	})
+	w, err := zw.Create("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zr
+}
+
+// checkRandomReads compares reads of the file at random offsets, in random order, with want.
+func checkRandomReads(t *testing.T, f *File, idx *Index, want []byte) {
+	t.Helper()
+	sr, err := f.OpenSeekable(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		off := rng.Int63n(int64(len(want)))
+		p := make([]byte, rng.Intn(100000))
+		n, err := sr.ReadAt(p, off)
+		end := off + int64(len(p))
+		if end > int64(len(want)) {
+			end = int64(len(want))
+		}
+		if n != int(end-off) || (err != nil && err != io.EOF) {
+			t.Fatalf("ReadAt(%d bytes, %d) = %d, %v, want %d bytes", len(p), off, n, err, end-off)
+		}
+		if !bytes.Equal(p[:n], want[off:end]) {
+			t.Fatalf("ReadAt(%d bytes, %d) returned different data", len(p), off)
+		}
+	}
+
+	// Read the rest of the file from a checkpoint through Seek.
+	off := int64(len(want)) / 3
+	if _, err := sr.Seek(off, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	rest, err := io.ReadAll(sr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(rest, want[off:]) {
+		t.Errorf("reading from offset %d returned different data", off)
+	}
+}
+
+func TestIndexDeflate(t *testing.T) {
+	inputs := flateInputs()
+	var data []byte
+	for _, name := range []string{"lorem", "random", "run", "words", "mixed"} {
+		data = append(data, inputs[name]...)
+	}
+	zr := newDeflateArchive(t, data)
+	raw, err := zr.File[0].OpenRaw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := io.ReadAll(flate.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := zr.File[0].BuildIndex(64 << 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.Checkpoints) < 5 {
+		t.Fatalf("index has %d checkpoints, want at least 5", len(idx.Checkpoints))
+	}
+	// Resume from the checkpoints of an index restored from its encoding.
+	b, err := idx.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx, err = UnmarshalIndex(b); err != nil {
+		t.Fatal(err)
+	}
+	checkRandomReads(t, zr.File[0], idx, want)
+}
+
+func TestIndexDeflate64(t *testing.T) {
+	rc, err := OpenReader(filepath.Join("testdata", "deflate64.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	for _, f := range rc.File {
+		if f.Name != "window.bin" {
+			continue
+		}
+		// A checkpoint at every block, whose windows are larger than Deflate's.
+		idx, err := f.BuildIndex(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(idx.Checkpoints) != 3 {
+			t.Fatalf("index has %d checkpoints, want 3", len(idx.Checkpoints))
+		}
+		checkRandomReads(t, f, idx, []byte(deflate64Content()["window.bin"]))
+	}
+}
+
+func TestIndexMismatch(t *testing.T) {
+	zr := newDeflateArchive(t, flateInputs()["words"])
+	idx, err := zr.File[0].BuildIndex(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.Span != DefaultIndexSpan {
+		t.Errorf("Span = %d, want %d", idx.Span, DefaultIndexSpan)
+	}
+	other := newDeflateArchive(t, flateInputs()["lorem"])
+	if _, err := other.File[0].OpenSeekable(idx); !errors.Is(err, ErrIndex) {
+		t.Errorf("OpenSeekable with the index of another file: error = %v, want %v", err, ErrIndex)
+	}
+}
@@ -0,0 +1,589 @@
+package reader
+
+import (
+	"errors"
+	"io"
+)
+
+// This file contains a DEFLATE decompressor (RFC 1951) that, unlike compress/flate,
+// exposes its position and history window between blocks. That allows decompression
+// to be resumed from the start of any block, which is what Index is built on.
+//
+// It also decompresses Deflate64, which differs only by a 64 KiB window, distance
+// codes 30 and 31, and length code 285 carrying 16 extra bits.
+
+var errInflate = errors.New("zip: invalid deflate data")
+
+const (
+	maxCodeLen  = 15  // maximum length of a Huffman code
+	maxNumLit   = 288 // number of literal/length codes
+	maxNumDist  = 32  // number of distance codes
+	numCodeLens = 19  // number of code length codes
+	fastBits    = 9   // codes up to this length are decoded with a single table lookup
+
+	deflateWindowSize = 32 << 10
+)
+
+var (
+	// codeLengthOrder is the order in which code length code lengths are stored.
+	codeLengthOrder = [numCodeLens]int{16, 17, 18, 0, 8, 7, 9, 6, 10, 5, 11, 4, 12, 3, 13, 2, 14, 1, 15}
+
+	lengthBase  = [29]uint16{3, 4, 5, 6, 7, 8, 9, 10, 11, 13, 15, 17, 19, 23, 27, 31, 35, 43, 51, 59, 67, 83, 99, 115, 131, 163, 195, 227, 258}
+	lengthExtra = [29]uint8{0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 2, 2, 3, 3, 3, 3, 4, 4, 4, 4, 5, 5, 5, 5, 0}
+	distBase    = [maxNumDist]uint32{1, 2, 3, 4, 5, 7, 9, 13, 17, 25, 33, 49, 65, 97, 129, 193, 257, 385, 513, 769, 1025, 1537, 2049, 3073, 4097, 6145, 8193, 12289, 16385, 24577, 32769, 49153}
+	distExtra   = [maxNumDist]uint8{0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6, 7, 7, 8, 8, 9, 9, 10, 10, 11, 11, 12, 12, 13, 13, 14, 14}
+)
+
+// huffman is a canonical Huffman decoding table.
+type huffman struct {
+	count  [maxCodeLen + 1]uint16 // number of codes of each length
+	symbol []uint16               // symbols ordered by code
+	// fast maps the next fastBits bits of input (in stream order) to symbol<<4 | length
+	// for codes no longer than fastBits. A zero entry requires the slow path.
+	fast [1 << fastBits]uint16
+}
+
+// init builds the table from a list of code lengths, one per symbol.
+func (h *huffman) init(lengths []uint8) error {
+	*h = huffman{symbol: h.symbol[:0]}
+	for _, l := range lengths {
+		h.count[l]++
+	}
+	h.count[0] = 0
+	left := 1
+	for l := 1; l <= maxCodeLen; l++ {
+		left <<= 1
+		left -= int(h.count[l])
+		if left < 0 {
+			return errInflate // over-subscribed
+		}
+	}
+
+	// Assign codes in canonical order: by length, then by symbol.
+	var offs [maxCodeLen + 2]int
+	for l := 1; l <= maxCodeLen; l++ {
+		offs[l+1] = offs[l] + int(h.count[l])
+	}
+	n := offs[maxCodeLen+1]
+	if cap(h.symbol) < n {
+		h.symbol = make([]uint16, n)
+	}
+	h.symbol = h.symbol[:n]
+	for sym, l := range lengths {
+		if l != 0 {
+			h.symbol[offs[l]] = uint16(sym)
+			offs[l]++
+		}
+	}
+
+	code, idx := 0, 0
+	for l := 1; l <= maxCodeLen; l++ {
+		for i := 0; i < int(h.count[l]); i++ {
+			if l <= fastBits {
+				rev := reverseBits(code, l)
+				for j := rev; j < 1<<fastBits; j += 1 << l {
+					h.fast[j] = h.symbol[idx]<<4 | uint16(l)
+				}
+			}
+			code++
+			idx++
+		}
+		code <<= 1
+	}
+	return nil
+}
+
+func reverseBits(code, n int) int {
+	rev := 0
+	for i := 0; i < n; i++ {
+		rev = rev<<1 | code&1
+		code >>= 1
+	}
+	return rev
+}
+
+var fixedLit, fixedDist huffman
+
+func init() {
+	var lengths [maxNumLit]uint8
+	for i := range lengths {
+		switch {
+		case i < 144:
+			lengths[i] = 8
+		case i < 256:
+			lengths[i] = 9
+		case i < 280:
+			lengths[i] = 7
+		default:
+			lengths[i] = 8
+		}
+	}
+	fixedLit.init(lengths[:])
+	var dist [maxNumDist]uint8
+	for i := range dist {
+		dist[i] = 5
+	}
+	fixedDist.init(dist[:])
+}
+
+// flateReader is the input of an inflater. Symbols are read a byte at a time,
+// the contents of stored blocks all at once.
+type flateReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// inflater decompresses a raw DEFLATE (or Deflate64) stream.
+type inflater struct {
+	r        flateReader
+	consumed int64  // bytes read from r
+	bits     uint64 // bit buffer, least significant bits first
+	nbits    uint
+
+	deflate64 bool
+	hist      []byte // history window (a ring buffer)
+	hpos      int    // next write position in hist
+	hlen      int    // number of valid bytes in hist
+	out       int64  // number of bytes written
+
+	// Block state.
+	final    bool
+	inBlock  bool
+	stored   int // remaining bytes of a stored block, or -1 for a Huffman block
+	lit      *huffman
+	dist     *huffman
+	dynLit   huffman
+	dynDist  huffman
+	copyLen  int // remaining bytes of a pending back-reference
+	copyDist int
+
+	// onBlock, if set, is called at the start of each block other than the first.
+	onBlock func(f *inflater)
+	err     error
+}
+
+// newInflater returns a decompressor reading from r.
+// If dict is set it is used as the history preceding the stream.
+func newInflater(r flateReader, deflate64 bool, dict []byte) *inflater {
+	size := deflateWindowSize
+	if deflate64 {
+		size = 2 * deflateWindowSize
+	}
+	f := &inflater{
+		r:         r,
+		deflate64: deflate64,
+		hist:      make([]byte, size),
+	}
+	if len(dict) > size {
+		dict = dict[len(dict)-size:]
+	}
+	f.hpos = copy(f.hist, dict) % size
+	f.hlen = len(dict)
+	return f
+}
+
+// bitOffset returns the number of bits of input consumed so far.
+func (f *inflater) bitOffset() int64 {
+	return f.consumed*8 - int64(f.nbits)
+}
+
+// window returns a copy of the history window, oldest byte first.
+func (f *inflater) window() []byte {
+	w := make([]byte, f.hlen)
+	start := f.hpos - f.hlen
+	if start < 0 {
+		n := copy(w, f.hist[len(f.hist)+start:])
+		copy(w[n:], f.hist[:f.hpos])
+	} else {
+		copy(w, f.hist[start:f.hpos])
+	}
+	return w
+}
+
+// unused returns the bytes read from r beyond the end of the compressed data,
+// which is only known once Read has returned io.EOF.
+func (f *inflater) unused() []byte {
+	bits, nbits := f.bits>>(f.nbits%8), f.nbits/8*8
+	b := make([]byte, 0, nbits/8)
+	for ; nbits > 0; nbits -= 8 {
+		b = append(b, byte(bits))
+		bits >>= 8
+	}
+	return b
+}
+
+// skipBits discards n bits of input, used when resuming in the middle of a byte.
+func (f *inflater) skipBits(n uint) error {
+	if err := f.need(n); err != nil {
+		return err
+	}
+	f.take(n)
+	return nil
+}
+
+// need ensures at least n bits are buffered.
+func (f *inflater) need(n uint) error {
+	for f.nbits < n {
+		b, err := f.r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		f.bits |= uint64(b) << f.nbits
+		f.nbits += 8
+		f.consumed++
+	}
+	return nil
+}
+
+func (f *inflater) take(n uint) uint32 {
+	v := uint32(f.bits & (1<<n - 1))
+	f.bits >>= n
+	f.nbits -= n
+	return v
+}
+
+func (f *inflater) readBits(n uint) (uint32, error) {
+	if err := f.need(n); err != nil {
+		return 0, err
+	}
+	return f.take(n), nil
+}
+
+// decode reads the next symbol using h.
+func (f *inflater) decode(h *huffman) (int, error) {
+	// Buffer enough bits for the fast path if the input allows it.
+	for f.nbits < fastBits {
+		b, err := f.r.ReadByte()
+		if err != nil {
+			break
+		}
+		f.bits |= uint64(b) << f.nbits
+		f.nbits += 8
+		f.consumed++
+	}
+	if e := h.fast[f.bits&(1<<fastBits-1)]; e != 0 && uint(e&15) <= f.nbits {
+		f.take(uint(e & 15))
+		return int(e >> 4), nil
+	}
+
+	// Slow path: decode one bit at a time.
+	code, first, index := 0, 0, 0
+	for l := 1; l <= maxCodeLen; l++ {
+		bit, err := f.readBits(1)
+		if err != nil {
+			return 0, err
+		}
+		code |= int(bit)
+		count := int(h.count[l])
+		if code-count < first {
+			return int(h.symbol[index+code-first]), nil
+		}
+		index += count
+		first += count
+		first <<= 1
+		code <<= 1
+	}
+	return 0, errInflate
+}
+
+func (f *inflater) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) && f.err == nil {
+		switch {
+		case f.copyLen > 0:
+			n += f.copyBack(p[n:])
+		case f.inBlock && f.stored >= 0:
+			n += f.readStored(p[n:])
+		case f.inBlock:
+			f.err = f.decodeSymbol(p, &n)
+		case f.final:
+			f.err = io.EOF
+		default:
+			f.err = f.readBlockHeader()
+		}
+	}
+	if n > 0 && f.err == io.EOF {
+		return n, nil
+	}
+	return n, f.err
+}
+
+// emit writes a byte to the output and history window.
+func (f *inflater) emit(p []byte, n *int, b byte) {
+	p[*n] = b
+	*n++
+	f.hist[f.hpos] = b
+	f.hpos++
+	if f.hpos == len(f.hist) {
+		f.hpos = 0
+	}
+	if f.hlen < len(f.hist) {
+		f.hlen++
+	}
+	f.out++
+}
+
+// advance accounts for n bytes written to the history window at hpos.
+func (f *inflater) advance(n int) {
+	f.hpos = (f.hpos + n) % len(f.hist)
+	if f.hlen += n; f.hlen > len(f.hist) {
+		f.hlen = len(f.hist)
+	}
+	f.out += int64(n)
+}
+
+// copyBack copies as much of the pending back-reference as fits in p.
+// The bytes are copied within the history window first, like compress/flate does,
+// which takes care of references overlapping the bytes they produce.
+func (f *inflater) copyBack(p []byte) int {
+	n := 0
+	for n < len(p) && f.copyLen > 0 {
+		// Copy up to the end of the window, the rest wraps around in the next iteration.
+		k := f.copyLen
+		if k > len(p)-n {
+			k = len(p) - n
+		}
+		if k > len(f.hist)-f.hpos {
+			k = len(f.hist) - f.hpos
+		}
+		src := f.hpos - f.copyDist
+		if src < 0 {
+			src += len(f.hist)
+		}
+		dst, end := f.hpos, f.hpos+k
+		for dst < end {
+			if src < dst {
+				// The copied bytes repeat every copyDist bytes, so the source can grow with them.
+				dst += copy(f.hist[dst:end], f.hist[src:dst])
+				continue
+			}
+			m := copy(f.hist[dst:end], f.hist[src:])
+			dst += m
+			if src += m; src == len(f.hist) {
+				src = 0
+			}
+		}
+		copy(p[n:], f.hist[f.hpos:end])
+		f.advance(k)
+		n += k
+		f.copyLen -= k
+	}
+	return n
+}
+
+func (f *inflater) readStored(p []byte) int {
+	n := 0
+	// Stored blocks are byte-aligned, so the bit buffer holds whole bytes.
+	for n < len(p) && f.stored > 0 && f.nbits >= 8 {
+		f.emit(p, &n, byte(f.take(8)))
+		f.stored--
+	}
+	for n < len(p) && f.stored > 0 {
+		k := f.stored
+		if k > len(p)-n {
+			k = len(p) - n
+		}
+		if k > len(f.hist)-f.hpos {
+			k = len(f.hist) - f.hpos
+		}
+		m, err := io.ReadFull(f.r, f.hist[f.hpos:f.hpos+k])
+		copy(p[n:], f.hist[f.hpos:f.hpos+m])
+		f.advance(m)
+		f.consumed += int64(m)
+		f.stored -= m
+		n += m
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			f.err = err
+			return n
+		}
+	}
+	if f.stored == 0 {
+		f.inBlock = false
+	}
+	return n
+}
+
+func (f *inflater) decodeSymbol(p []byte, n *int) error {
+	sym, err := f.decode(f.lit)
+	if err != nil {
+		return err
+	}
+	switch {
+	case sym < 256:
+		f.emit(p, n, byte(sym))
+		return nil
+	case sym == 256:
+		f.inBlock = false
+		return nil
+	case sym > 285:
+		return errInflate
+	}
+
+	sym -= 257
+	length := int(lengthBase[sym])
+	extra := uint(lengthExtra[sym])
+	if f.deflate64 && sym == 28 {
+		// Deflate64 redefines the last length code as 3 plus 16 extra bits.
+		length, extra = 3, 16
+	}
+	if extra > 0 {
+		v, err := f.readBits(extra)
+		if err != nil {
+			return err
+		}
+		length += int(v)
+	}
+
+	dsym, err := f.decode(f.dist)
+	if err != nil {
+		return err
+	}
+	if dsym >= 30 && !f.deflate64 || dsym >= maxNumDist {
+		return errInflate
+	}
+	dist := int(distBase[dsym])
+	if extra := uint(distExtra[dsym]); extra > 0 {
+		v, err := f.readBits(extra)
+		if err != nil {
+			return err
+		}
+		dist += int(v)
+	}
+	if dist > f.hlen {
+		return errInflate
+	}
+	f.copyLen, f.copyDist = length, dist
+	return nil
+}
+
+func (f *inflater) readBlockHeader() error {
+	if f.out > 0 && f.onBlock != nil {
+		f.onBlock(f)
+	}
+	header, err := f.readBits(3)
+	if err != nil {
+		return err
+	}
+	f.final = header&1 == 1
+	switch header >> 1 {
+	case 0:
+		// Stored blocks start at a byte boundary.
+		f.take(f.nbits % 8)
+		lengths, err := f.readBits(32)
+		if err != nil {
+			return err
+		}
+		length, nlength := uint16(lengths), uint16(lengths>>16)
+		if length != ^nlength {
+			return errInflate
+		}
+		f.stored = int(length)
+		f.inBlock = f.stored > 0
+	case 1:
+		f.stored = -1
+		f.lit, f.dist = &fixedLit, &fixedDist
+		f.inBlock = true
+	case 2:
+		if err := f.readDynamicTables(); err != nil {
+			return err
+		}
+		f.stored = -1
+		f.lit, f.dist = &f.dynLit, &f.dynDist
+		f.inBlock = true
+	default:
+		return errInflate
+	}
+	return nil
+}
+
+func (f *inflater) readDynamicTables() error {
+	counts, err := f.readBits(14)
+	if err != nil {
+		return err
+	}
+	nlit := int(counts&0x1f) + 257
+	ndist := int(counts>>5&0x1f) + 1
+	nclen := int(counts>>10) + 4
+	if nlit > 286 && !f.deflate64 || nlit > maxNumLit {
+		return errInflate
+	}
+
+	var clens [numCodeLens]uint8
+	for i := 0; i < nclen; i++ {
+		v, err := f.readBits(3)
+		if err != nil {
+			return err
+		}
+		clens[codeLengthOrder[i]] = uint8(v)
+	}
+	var clh huffman
+	if err := clh.init(clens[:]); err != nil {
+		return err
+	}
+
+	var lengths [maxNumLit + maxNumDist]uint8
+	for i := 0; i < nlit+ndist; {
+		sym, err := f.decode(&clh)
+		if err != nil {
+			return err
+		}
+		if sym < 16 {
+			lengths[i] = uint8(sym)
+			i++
+			continue
+		}
+		var rep int
+		var val uint8
+		switch sym {
+		case 16:
+			if i == 0 {
+				return errInflate
+			}
+			val = lengths[i-1]
+			v, err := f.readBits(2)
+			if err != nil {
+				return err
+			}
+			rep = 3 + int(v)
+		case 17:
+			v, err := f.readBits(3)
+			if err != nil {
+				return err
+			}
+			rep = 3 + int(v)
+		default:
+			v, err := f.readBits(7)
+			if err != nil {
+				return err
+			}
+			rep = 11 + int(v)
+		}
+		if i+rep > nlit+ndist {
+			return errInflate
+		}
+		for ; rep > 0; rep-- {
+			lengths[i] = val
+			i++
+		}
+	}
+	if lengths[256] == 0 {
+		return errInflate // no end of block code
+	}
+	if err := f.dynLit.init(lengths[:nlit]); err != nil {
+		return err
+	}
+	return f.dynDist.init(lengths[nlit : nlit+ndist])
+}
+
+func (f *inflater) Close() error {
+	if f.err == nil || f.err == io.EOF {
+		return nil
+	}
+	return f.err
+}
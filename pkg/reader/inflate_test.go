@@ -0,0 +1,133 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// deflate64Content returns the contents of the files in testdata/deflate64.zip, see make-fixtures.sh.
+// window.bin repeats data at distances beyond the 32 KiB window of Deflate and ends with
+// a run longer than the longest Deflate64 match.
+func deflate64Content() map[string]string {
+	want := testContent()
+	x, rnd := uint32(1), make([]byte, 40000)
+	for i := range rnd {
+		x = (x*1103515245 + 12345) & 0x7fffffff
+		rnd[i] = byte(x >> 16)
+	}
+	text := want["lorem.txt"][:20000]
+	want["window.bin"] = string(rnd) + text + string(rnd[:20000]) + text + strings.Repeat("z", 70000)
+	return want
+}
+
+func TestDeflate64(t *testing.T) {
+	rc, err := OpenReader(filepath.Join("testdata", "deflate64.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	checkEntries(t, &rc.Reader, Deflate64, deflate64Content())
+}
+
+func TestDeflate64CodesRejectedByDeflate(t *testing.T) {
+	rc, err := OpenReader(filepath.Join("testdata", "deflate64.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	for _, f := range rc.File {
+		if f.Name != "window.bin" {
+			continue
+		}
+		raw, err := f.OpenRaw()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.Copy(io.Discard, newInflater(bufio.NewReader(raw), false, nil)); err != errInflate {
+			t.Errorf("inflating Deflate64 data as Deflate: error = %v, want %v", err, errInflate)
+		}
+	}
+}
+
+// flateInputs returns data that makes compress/flate use stored, fixed and dynamic blocks,
+// matches of all lengths and distances, and multiple blocks.
+func flateInputs() map[string][]byte {
+	rng := rand.New(rand.NewSource(1))
+	random := make([]byte, 100000)
+	rng.Read(random)
+	// Words from a small alphabet compress well but still vary enough for dynamic blocks.
+	var words bytes.Buffer
+	for words.Len() < 300000 {
+		for i, n := 0, 1+rng.Intn(8); i < n; i++ {
+			words.WriteByte("abcdefgh"[rng.Intn(8)])
+		}
+		words.WriteByte(' ')
+	}
+	return map[string][]byte{
+		"empty":  nil,
+		"byte":   {'x'},
+		"run":    bytes.Repeat([]byte{'z'}, 100000),
+		"lorem":  []byte(testContent()["lorem.txt"]),
+		"random": random,
+		"words":  words.Bytes(),
+		"mixed":  append(append(append([]byte{}, random[:40000]...), words.Bytes()[:100000]...), random[:40000]...),
+	}
+}
+
+func TestInflateMatchesFlate(t *testing.T) {
+	levels := []int{flate.NoCompression, flate.HuffmanOnly, flate.BestSpeed, flate.DefaultCompression, flate.BestCompression}
+	for name, data := range flateInputs() {
+		for _, level := range levels {
+			var compressed bytes.Buffer
+			fw, err := flate.NewWriter(&compressed, level)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fw.Write(data)
+			fw.Close()
+
+			want, err := io.ReadAll(flate.NewReader(bytes.NewReader(compressed.Bytes())))
+			if err != nil {
+				t.Fatalf("%s (level %d): compress/flate: %v", name, level, err)
+			}
+			// Small reads stop the inflater in the middle of back-references and stored blocks.
+			for _, r := range []io.Reader{
+				newInflater(bytes.NewReader(compressed.Bytes()), false, nil),
+				iotest.OneByteReader(newInflater(bytes.NewReader(compressed.Bytes()), false, nil)),
+			} {
+				got, err := io.ReadAll(r)
+				if err != nil {
+					t.Errorf("%s (level %d): %v", name, level, err)
+				} else if !bytes.Equal(got, want) {
+					t.Errorf("%s (level %d): output differs from compress/flate, got %d bytes, want %d bytes", name, level, len(got), len(want))
+				}
+			}
+		}
+	}
+}
+
+func TestInflateCorrupt(t *testing.T) {
+	var compressed bytes.Buffer
+	fw, _ := flate.NewWriter(&compressed, flate.BestCompression)
+	fw.Write(flateInputs()["words"])
+	fw.Close()
+	b := compressed.Bytes()
+
+	_, err := io.Copy(io.Discard, newInflater(bytes.NewReader(b[:len(b)/2]), false, nil))
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("truncated data: error = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	// An invalid block type.
+	_, err = io.Copy(io.Discard, newInflater(bytes.NewReader([]byte{0x07}), false, nil))
+	if err != errInflate {
+		t.Errorf("invalid block type: error = %v, want %v", err, errInflate)
+	}
+}
@@ -0,0 +1,107 @@
+package reader
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrLimit is matched (with errors.Is) by every *LimitError.
+var ErrLimit = errors.New("zip: resource limit exceeded")
+
+// Limits bounds the resources used to read an archive, to guard against zip bombs
+// and other malicious archives. A zero field means no limit.
+//
+// Sizes are counted as the contents are decompressed, rather than trusting
+// the sizes recorded in the archive.
+type Limits struct {
+	MaxEntries       uint64 // number of files in the archive
+	MaxDirectorySize uint64 // size of the central directory in bytes, checked before it is read into memory
+	MaxEntrySize     uint64 // uncompressed bytes read from a single file
+	MaxTotalSize     uint64 // uncompressed bytes read from all files of the archive combined
+	// MaxRatio bounds the uncompressed bytes read from a Deflate or Deflate64 compressed file per byte
+	// of its compressed data, once more than MinRatioSize bytes have been read from it. Deflate can't
+	// compress better than about 1032:1, whereas other methods compress runs of repeated data
+	// arbitrarily well, so their ratio doesn't tell legitimate files apart from bombs.
+	MaxRatio uint64
+}
+
+// MinRatioSize is the number of bytes read from a file before its compression ratio is checked,
+// so that small, highly compressible files never exceed Limits.MaxRatio.
+const MinRatioSize = 1 << 20 // 1 MiB
+
+// Names of the limits reported by LimitError.
+const (
+	LimitEntries       = "number of entries"
+	LimitDirectorySize = "central directory size"
+	LimitEntrySize     = "uncompressed size"
+	LimitTotalSize     = "total uncompressed size"
+	LimitRatio         = "compression ratio"
+)
+
+// A LimitError is returned when reading an archive would exceed one of its Limits.
+type LimitError struct {
+	Limit string // the limit that was exceeded, e.g. LimitEntrySize
+	Max   uint64 // value of the limit
+	Name  string // name of the file being read, empty for limits on the central directory
+}
+
+func (e *LimitError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("zip: %s exceeds limit (limit: %d)", e.Limit, e.Max)
+	}
+	return fmt.Sprintf("zip: %s exceeds limit (name: %s) (limit: %d)", e.Limit, e.Name, e.Max)
+}
+
+// Is reports whether target is ErrLimit.
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimit
+}
+
+// WithLimits sets limits on the resources used to read the archive.
+func WithLimits(limits Limits) Option {
+	return func(z *Reader) {
+		z.limits = limits
+	}
+}
+
+// checkDirectory checks the size and number of records of a central directory.
+func (l *Limits) checkDirectory(size, records uint64) error {
+	if l.MaxDirectorySize > 0 && size > l.MaxDirectorySize {
+		return &LimitError{Limit: LimitDirectorySize, Max: l.MaxDirectorySize}
+	}
+	return l.checkEntries(records)
+}
+
+func (l *Limits) checkEntries(n uint64) error {
+	if l.MaxEntries > 0 && n > l.MaxEntries {
+		return &LimitError{Limit: LimitEntries, Max: l.MaxEntries}
+	}
+	return nil
+}
+
+// checkOpen checks the sizes recorded for a file before it is read.
+func (l *Limits) checkOpen(f *File) error {
+	if l.MaxEntrySize > 0 && f.UncompressedSize64 > l.MaxEntrySize {
+		return &LimitError{Limit: LimitEntrySize, Max: l.MaxEntrySize, Name: f.Name}
+	}
+	return nil
+}
+
+// checkRead counts n more uncompressed bytes read from f, nread in total.
+func (z *Reader) checkRead(f *File, n int, nread uint64) error {
+	l := &z.limits
+	if l.MaxEntrySize > 0 && nread > l.MaxEntrySize {
+		return &LimitError{Limit: LimitEntrySize, Max: l.MaxEntrySize, Name: f.Name}
+	}
+	if method := f.CompressionMethod(); l.MaxRatio > 0 && nread > MinRatioSize && (method == Deflate || method == Deflate64) {
+		// The compressed size isn't known up front for streamed files with a data descriptor.
+		if c := f.CompressedSize64; c > 0 && nread/c > l.MaxRatio {
+			return &LimitError{Limit: LimitRatio, Max: l.MaxRatio, Name: f.Name}
+		}
+	}
+	if l.MaxTotalSize > 0 && atomic.AddUint64(&z.nread, uint64(n)) > l.MaxTotalSize {
+		return &LimitError{Limit: LimitTotalSize, Max: l.MaxTotalSize, Name: f.Name}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package reader
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"io"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestMaxRatio(t *testing.T) {
+	small := make([]byte, MinRatioSize/2)
+	large := make([]byte, 2*MinRatioSize)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range map[string][]byte{"small.deflate": small, "large.deflate": large} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed := enc.EncodeAll(large, nil)
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               "large.zstd",
+		Method:             Zstd,
+		CRC32:              crc32.ChecksumIEEE(large),
+		CompressedSize64:   uint64(len(compressed)),
+		UncompressedSize64: uint64(len(large)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(compressed)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()), WithLimits(Limits{MaxRatio: 100}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range zr.File {
+		if ratio := f.UncompressedSize64 / f.CompressedSize64; ratio <= 100 {
+			t.Fatalf("%s: ratio = %d, want more than the limit", f.Name, ratio)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = io.Copy(io.Discard, rc)
+		rc.Close()
+
+		// Only large Deflate files are subject to the limit.
+		var limitErr *LimitError
+		if f.Name == "large.deflate" {
+			if !errors.As(err, &limitErr) || limitErr.Limit != LimitRatio {
+				t.Errorf("%s: error = %v, want a compression ratio LimitError", f.Name, err)
+			}
+		} else if err != nil {
+			t.Errorf("%s: %v", f.Name, err)
+		}
+	}
+}
+
+func TestDirectoryLimits(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if _, err := zw.Create(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	directory, err := zr.MarshalDirectory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		limits Limits
+		want   string // limit exceeded, empty for none
+	}{
+		{limits: Limits{}},
+		{limits: Limits{MaxEntries: 3, MaxDirectorySize: zr.directorySize}},
+		{limits: Limits{MaxEntries: 2}, want: LimitEntries},
+		{limits: Limits{MaxDirectorySize: zr.directorySize - 1}, want: LimitDirectorySize},
+	}
+	for _, tt := range tests {
+		// A directory read from a cache is subject to the same limits as one read from the archive.
+		_, archiveErr := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()), WithLimits(tt.limits))
+		_, cacheErr := NewReaderFromDirectory(bytes.NewReader(buf.Bytes()), directory, WithLimits(tt.limits))
+		for _, err := range []error{archiveErr, cacheErr} {
+			var limitErr *LimitError
+			if tt.want == "" && err != nil {
+				t.Errorf("%+v: %v", tt.limits, err)
+			} else if tt.want != "" && (!errors.As(err, &limitErr) || limitErr.Limit != tt.want) {
+				t.Errorf("%+v: error = %v, want a %s LimitError", tt.limits, err, tt.want)
+			}
+		}
+	}
+}
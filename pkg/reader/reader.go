@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"hash"
 	"hash/crc32"
 	"io"
@@ -26,12 +27,28 @@ var (
 	ErrChecksum  = errors.New("zip: checksum error")
 )
 
+// ErrNoDirectoryEnd is returned when the end of central directory record can't be found,
+// e.g. because the archive is truncated. It wraps ErrFormat.
+var ErrNoDirectoryEnd = fmt.Errorf("%w: end of central directory record not found", ErrFormat)
+
 // A Reader serves content from a ZIP archive.
 type Reader struct {
+	// nread is the number of uncompressed bytes read from all files, accessed atomically.
+	// It is the first field to keep it 64-bit aligned on 32-bit platforms.
+	nread uint64
+
 	r             io.ReaderAt
 	File          []*File
 	Comment       string
 	decompressors map[uint16]Decompressor
+	password      []byte // nil if no password was given
+	limits        Limits
+
+	// directoryOffset is the offset of the central directory,
+	// or 0 if the Reader wasn't created from the archive's central directory.
+	directoryOffset int64
+	// directorySize is the size of the central directory in bytes, or 0 if it wasn't read.
+	directorySize uint64
 
 	// fileList is a list of files sorted by ename,
 	// for use by the Open method.
@@ -53,12 +70,23 @@ type File struct {
 	zip          *Reader
 	zipr         io.ReaderAt
 	headerOffset int64
-	zip64        bool  // zip64 extended information extra field presence
-	descErr      error // error reading the data descriptor during init
+	zip64        bool      // zip64 extended information extra field presence
+	aes          *aesExtra // WinZip AES extra field, if present
+	descErr      error     // error reading the data descriptor during init
+}
+
+// An Option configures a Reader.
+type Option func(*Reader)
+
+// WithPassword sets the password used to decrypt encrypted files.
+func WithPassword(password string) Option {
+	return func(z *Reader) {
+		z.password = []byte(password)
+	}
 }
 
 // OpenReader will open the Zip file specified by name and return a ReadCloser.
-func OpenReader(name string) (*ReadCloser, error) {
+func OpenReader(name string, opts ...Option) (*ReadCloser, error) {
 	f, err := os.Open(name)
 	if err != nil {
 		return nil, err
@@ -69,6 +97,9 @@ func OpenReader(name string) (*ReadCloser, error) {
 		return nil, err
 	}
 	r := new(ReadCloser)
+	for _, opt := range opts {
+		opt(&r.Reader)
+	}
 	if err := r.init(f, fi.Size()); err != nil {
 		f.Close()
 		return nil, err
@@ -79,11 +110,14 @@ func OpenReader(name string) (*ReadCloser, error) {
 
 // NewReader returns a new Reader reading from r, which is assumed to
 // have the given size in bytes.
-func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+func NewReader(r io.ReaderAt, size int64, opts ...Option) (*Reader, error) {
 	if size < 0 {
 		return nil, errors.New("zip: size cannot be negative")
 	}
 	zr := new(Reader)
+	for _, opt := range opts {
+		opt(zr)
+	}
 	if err := zr.init(r, size); err != nil {
 		return nil, err
 	}
@@ -95,7 +129,16 @@ func (z *Reader) init(r io.ReaderAt, size int64) error {
 	if err != nil {
 		return err
 	}
+	// The directory is read into memory, so check its size before allocating it.
+	if end.directorySize > uint64(size)-end.directoryOffset {
+		return ErrFormat
+	}
+	if err := z.limits.checkDirectory(end.directorySize, end.directoryRecords); err != nil {
+		return err
+	}
 	z.r = r
+	z.directoryOffset = int64(end.directoryOffset)
+	z.directorySize = end.directorySize
 	// Since the number of directory records is not validated, it is not
 	// safe to preallocate z.File without first checking that the specified
 	// number of files is reasonable, since a malformed archive may
@@ -128,6 +171,10 @@ func (z *Reader) init(r io.ReaderAt, size int64) error {
 		}
 		f.readDataDescriptor()
 		z.File = append(z.File, f)
+		// The number of records may have been truncated, so check the files actually read too.
+		if err := z.limits.checkEntries(uint64(len(z.File))); err != nil {
+			return err
+		}
 	}
 	if uint16(len(z.File)) != uint16(end.directoryRecords) { // only compare 16 bits here
 		// Return the readDirectoryHeader error if we read
@@ -173,24 +220,44 @@ func (f *File) DataOffset() (offset int64, err error) {
 	return f.headerOffset + bodyOffset, nil
 }
 
+// HeaderOffset returns the offset of the file's local file header,
+// relative to the beginning of the zip file.
+func (f *File) HeaderOffset() int64 {
+	return f.headerOffset
+}
+
 // Open returns a ReadCloser that provides access to the File's contents.
 // Multiple files may be read concurrently.
+// Encrypted files are decrypted with the Reader's password, see WithPassword.
 func (f *File) Open() (io.ReadCloser, error) {
+	if err := f.zip.limits.checkOpen(f); err != nil {
+		return nil, err
+	}
 	bodyOffset, err := f.findBodyOffset()
 	if err != nil {
 		return nil, err
 	}
 	size := int64(f.CompressedSize64)
-	r := io.NewSectionReader(f.zipr, f.headerOffset+bodyOffset, size)
-	dcomp := f.zip.decompressor(f.Method)
+	var r io.Reader = io.NewSectionReader(f.zipr, f.headerOffset+bodyOffset, size)
+	dcomp := f.zip.decompressor(f.CompressionMethod())
 	if dcomp == nil {
 		return nil, ErrAlgorithm
 	}
-	var rc io.ReadCloser = dcomp(r)
+	var verify func() error
+	if f.isEncrypted() {
+		if r, err = f.decrypt(r); err != nil {
+			return nil, err
+		}
+		if v, ok := r.(interface{ verify() error }); ok {
+			verify = v.verify
+		}
+	}
+	var rc io.ReadCloser = dcomp(&sizedReader{Reader: r, size: f.UncompressedSize64})
 	rc = &checksumReader{
-		rc:   rc,
-		hash: crc32.NewIEEE(),
-		f:    f,
+		rc:     rc,
+		hash:   crc32.NewIEEE(),
+		f:      f,
+		verify: verify,
 	}
 	return rc, nil
 }
@@ -244,11 +311,12 @@ func (f *File) readDataDescriptor() {
 }
 
 type checksumReader struct {
-	rc    io.ReadCloser
-	hash  hash.Hash32
-	nread uint64 // number of bytes read so far
-	f     *File
-	err   error // sticky error
+	rc     io.ReadCloser
+	hash   hash.Hash32
+	nread  uint64 // number of bytes read so far
+	f      *File
+	err    error        // sticky error
+	verify func() error // optional check of the raw data once it has all been read
 }
 
 func (r *checksumReader) Stat() (fs.FileInfo, error) {
@@ -262,6 +330,10 @@ func (r *checksumReader) Read(b []byte) (n int, err error) {
 	n, err = r.rc.Read(b)
 	r.hash.Write(b[:n])
 	r.nread += uint64(n)
+	if lerr := r.f.zip.checkRead(r.f, n, r.nread); lerr != nil {
+		r.err = lerr
+		return n, lerr
+	}
 	if err == nil {
 		return
 	}
@@ -269,6 +341,12 @@ func (r *checksumReader) Read(b []byte) (n int, err error) {
 		if r.nread != r.f.UncompressedSize64 {
 			return 0, io.ErrUnexpectedEOF
 		}
+		if r.verify != nil {
+			if verr := r.verify(); verr != nil {
+				r.err = verr
+				return n, verr
+			}
+		}
 		if r.f.hasDataDescriptor() {
 			if r.f.descErr != nil {
 				if r.f.descErr == io.EOF {
@@ -276,14 +354,15 @@ func (r *checksumReader) Read(b []byte) (n int, err error) {
 				} else {
 					err = r.f.descErr
 				}
-			} else if r.hash.Sum32() != r.f.CRC32 {
+			} else if !r.f.isAE2() && r.hash.Sum32() != r.f.CRC32 {
 				err = ErrChecksum
 			}
 		} else {
 			// If there's not a data descriptor, we still compare
 			// the CRC32 of what we've read against the file header
 			// or TOC's CRC32, if it seems like it was set.
-			if r.f.CRC32 != 0 && r.hash.Sum32() != r.f.CRC32 {
+			// AE-2 files are checked by their authentication code instead.
+			if !r.f.isAE2() && r.f.CRC32 != 0 && r.hash.Sum32() != r.f.CRC32 {
 				err = ErrChecksum
 			}
 		}
@@ -297,6 +376,9 @@ func (r *checksumReader) Close() error { return r.rc.Close() }
 // findBodyOffset does the minimum work to verify the file has a header
 // and returns the file body offset.
 func (f *File) findBodyOffset() (int64, error) {
+	if f.zipr == nil {
+		return 0, errStreamFile
+	}
 	var buf [fileHeaderLen]byte
 	if _, err := f.zipr.ReadAt(buf[:], f.headerOffset); err != nil {
 		return 0, err
@@ -348,6 +430,17 @@ func readDirectoryHeader(f *File, r io.Reader) error {
 	f.Extra = d[filenameLen : filenameLen+extraLen]
 	f.Comment = string(d[filenameLen+extraLen:])
 
+	needUSize := f.UncompressedSize == ^uint32(0)
+	needCSize := f.CompressedSize == ^uint32(0)
+	needHeaderOffset := f.headerOffset == int64(^uint32(0))
+	return f.readHeaderExtra(needUSize, needCSize, needHeaderOffset)
+}
+
+// readHeaderExtra determines the encoding of the name and comment, and reads
+// the extra fields of a central directory or local file header into f.
+// The sizes and header offset are read from the zip64 extra field if the
+// corresponding need flag is set, in which case its absence is an ErrFormat.
+func (f *File) readHeaderExtra(needUSize, needCSize, needHeaderOffset bool) error {
 	// Determine the character encoding.
 	utf8Valid1, utf8Require1 := detectUTF8(f.Name)
 	utf8Valid2, utf8Require2 := detectUTF8(f.Comment)
@@ -366,10 +459,6 @@ func readDirectoryHeader(f *File, r io.Reader) error {
 		f.NonUTF8 = f.Flags&0x800 == 0
 	}
 
-	needUSize := f.UncompressedSize == ^uint32(0)
-	needCSize := f.CompressedSize == ^uint32(0)
-	needHeaderOffset := f.headerOffset == int64(^uint32(0))
-
 	// Best effort to find what we need.
 	// Other zip authors might not even follow the basic format,
 	// and we'll just ignore the Extra content in that case.
@@ -435,6 +524,14 @@ parseExtras:
 				epoch := time.Date(1601, time.January, 1, 0, 0, 0, 0, time.UTC)
 				modified = time.Unix(epoch.Unix()+secs, nsecs)
 			}
+		case aesExtraID:
+			if len(fieldBuf) < 7 {
+				continue parseExtras
+			}
+			f.aes = &aesExtra{Version: fieldBuf.uint16()}
+			fieldBuf.uint16() // vendor ID "AE" (ignored)
+			f.aes.Strength = fieldBuf.uint8()
+			f.aes.Method = fieldBuf.uint16()
 		case unixExtraID, infoZipUnixExtraID:
 			if len(fieldBuf) < 8 {
 				continue parseExtras
@@ -551,7 +648,7 @@ func readDirectoryEnd(r io.ReaderAt, size int64) (dir *directoryEnd, err error)
 			break
 		}
 		if i == 1 || bLen == size {
-			return nil, ErrFormat
+			return nil, ErrNoDirectoryEnd
 		}
 	}
 
@@ -0,0 +1,100 @@
+package reader
+
+import (
+	"bytes"
+	"io"
+)
+
+// recoverBufferSize is the size of each read when searching for a local file header.
+const recoverBufferSize = 1 << 20 // 1 MiB
+
+var fileHeaderSig = []byte{'P', 'K', 0x03, 0x04}
+
+// Recoverer finds the files of a damaged archive, such as one that was truncated, by searching
+// it for local file headers. Unlike ScanLocalHeaders, it doesn't stop at the first damaged file:
+// the search resumes after the damaged file's header, so every intact file can be found.
+//
+// Since the search looks for the signature of a local file header anywhere in the archive,
+// the contents of damaged stored files (e.g. other zip archives) may be mistaken for files.
+type Recoverer struct {
+	r    io.ReaderAt
+	size int64
+	z    *Reader
+	off  int64 // offset from which to search for the next local file header
+	buf  []byte
+	cur  *StreamReader // reads the current file, starting at its local file header
+	base int64         // offset of cur's input in the archive
+}
+
+// NewRecoverer returns a Recoverer for the archive in r, which is assumed to have the given size in bytes.
+func NewRecoverer(r io.ReaderAt, size int64, opts ...Option) *Recoverer {
+	z := &Reader{r: r}
+	for _, opt := range opts {
+		opt(z)
+	}
+	return &Recoverer{r: r, size: size, z: z}
+}
+
+// Next advances to the next file, skipping the rest of the current one.
+// It returns io.EOF when no more local file headers can be found.
+// The returned File can be opened like one from a Reader once its contents have been read
+// (its sizes and checksum are only known at that point if it was written with a data descriptor).
+func (r *Recoverer) Next() (*File, error) {
+	if r.cur != nil {
+		f := r.cur.cur.f
+		if err := r.cur.finish(); err == nil {
+			r.off = r.base + r.cur.src.off
+		} else {
+			r.off = f.headerOffset + int64(len(fileHeaderSig))
+		}
+		r.cur = nil
+	}
+	for {
+		off, err := r.search()
+		if err != nil {
+			return nil, err
+		}
+		s := newStreamReader(io.NewSectionReader(r.r, off, r.size-off), r.size-off, r.z)
+		f, err := s.next()
+		if err != nil {
+			// Not a valid header after all, or one truncated by the end of the archive.
+			r.off = off + int64(len(fileHeaderSig))
+			continue
+		}
+		f.headerOffset = off
+		f.zipr = r.r
+		r.cur, r.base = s, off
+		return f, nil
+	}
+}
+
+// Read reads the decompressed contents of the current file.
+// The checksum is verified when the end of the file is reached.
+func (r *Recoverer) Read(p []byte) (int, error) {
+	if r.cur == nil {
+		return 0, io.EOF
+	}
+	return r.cur.Read(p)
+}
+
+// search returns the offset of the next local file header signature at or after r.off.
+func (r *Recoverer) search() (int64, error) {
+	if r.buf == nil {
+		r.buf = make([]byte, recoverBufferSize)
+	}
+	for r.off < r.size {
+		n, err := r.r.ReadAt(r.buf[:min64(uint64(len(r.buf)), uint64(r.size-r.off))], r.off)
+		if n == 0 && err != nil {
+			return 0, unexpectedEOF(err)
+		}
+		if i := bytes.Index(r.buf[:n], fileHeaderSig); i >= 0 {
+			return r.off + int64(i), nil
+		}
+		if r.off+int64(n) >= r.size {
+			break
+		}
+		// Keep the last few bytes in case the signature straddles the reads.
+		r.off += int64(n - len(fileHeaderSig) + 1)
+	}
+	return 0, io.EOF
+}
@@ -0,0 +1,139 @@
+package reader
+
+import (
+	"archive/zip"
+	"bytes"
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+)
+
+// recoverEntry is a file of a damaged archive, as found by a Recoverer.
+type recoverEntry struct {
+	name   string
+	offset int64
+	ok     bool
+}
+
+// newRecoverArchive returns an archive of deflated files with data descriptors and stored files without,
+// and the offsets of their local headers by name, from its central directory.
+func newRecoverArchive(t *testing.T) ([]byte, map[string]int64) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		data := []byte(strings.Repeat(name+" ", 1000))
+		var w io.Writer
+		var err error
+		if name == "b.txt" || name == "d.txt" {
+			w, err = zw.CreateRaw(&zip.FileHeader{
+				Name:               name,
+				Method:             zip.Store,
+				CRC32:              crc32.ChecksumIEEE(data),
+				CompressedSize64:   uint64(len(data)),
+				UncompressedSize64: uint64(len(data)),
+			})
+		} else {
+			w, err = zw.Create(name)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	offsets := make(map[string]int64)
+	for _, f := range zr.File {
+		offsets[f.Name] = f.HeaderOffset()
+	}
+	return buf.Bytes(), offsets
+}
+
+// recoverAll returns the files found in the archive by a Recoverer, reading each of them.
+func recoverAll(t *testing.T, data []byte) []recoverEntry {
+	t.Helper()
+	rec := NewRecoverer(bytes.NewReader(data), int64(len(data)))
+	var found []recoverEntry
+	for {
+		f, err := rec.Next()
+		if err == io.EOF {
+			return found
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(rec)
+		ok := err == nil
+		if ok && string(b) != strings.Repeat(f.Name+" ", 1000) {
+			t.Errorf("%s: recovered contents differ", f.Name)
+		}
+		found = append(found, recoverEntry{name: f.Name, offset: f.HeaderOffset(), ok: ok})
+	}
+}
+
+func TestRecoverer(t *testing.T) {
+	archive, offsets := newRecoverArchive(t)
+	tests := []struct {
+		name   string
+		damage func(b []byte) []byte
+		want   []recoverEntry
+	}{
+		{
+			name:   "intact",
+			damage: func(b []byte) []byte { return b },
+			want: []recoverEntry{
+				{"a.txt", offsets["a.txt"], true},
+				{"b.txt", offsets["b.txt"], true},
+				{"c.txt", offsets["c.txt"], true},
+				{"d.txt", offsets["d.txt"], true},
+			},
+		},
+		{
+			// Truncated in the middle of d.txt, without a central directory.
+			name:   "truncated",
+			damage: func(b []byte) []byte { return b[:offsets["d.txt"]+100] },
+			want: []recoverEntry{
+				{"a.txt", offsets["a.txt"], true},
+				{"b.txt", offsets["b.txt"], true},
+				{"c.txt", offsets["c.txt"], true},
+				{"d.txt", offsets["d.txt"], false},
+			},
+		},
+		{
+			// A byte of b.txt's stored data, and one of c.txt's compressed data, changed.
+			name: "corrupted",
+			damage: func(b []byte) []byte {
+				b = append([]byte(nil), b...)
+				b[offsets["b.txt"]+100] ^= 0xff
+				b[offsets["c.txt"]+50] ^= 0xff
+				return b
+			},
+			want: []recoverEntry{
+				{"a.txt", offsets["a.txt"], true},
+				{"b.txt", offsets["b.txt"], false},
+				{"c.txt", offsets["c.txt"], false},
+				{"d.txt", offsets["d.txt"], true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := recoverAll(t, tt.damage(archive))
+			if len(got) != len(tt.want) {
+				t.Fatalf("found %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("file %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
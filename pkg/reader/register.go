@@ -5,10 +5,19 @@
 package reader
 
 import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
 	"compress/flate"
+	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	"github.com/ulikunitz/xz/lzma"
 )
 
 // A Compressor returns a new compressing writer, writing to w.
@@ -101,6 +110,77 @@ func (r *pooledFlateReader) Close() error {
 	return err
 }
 
+// sizedReader is the reader passed to decompressors by File.Open.
+// It carries the uncompressed size of the file for formats such as LZMA,
+// whose data doesn't necessarily mark where it ends.
+type sizedReader struct {
+	io.Reader
+	size uint64
+}
+
+func newDeflate64Reader(r io.Reader) io.ReadCloser {
+	return newInflater(bufio.NewReader(r), true, nil)
+}
+
+func newBzip2Reader(r io.Reader) io.ReadCloser {
+	return io.NopCloser(bzip2.NewReader(r))
+}
+
+func newZstdReader(r io.Reader) io.ReadCloser {
+	// Files are decompressed concurrently by callers, so don't start extra goroutines per file.
+	d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return &errReader{err}
+	}
+	return d.IOReadCloser()
+}
+
+func newXZReader(r io.Reader) io.ReadCloser {
+	xr, err := xz.NewReader(r)
+	if err != nil {
+		return &errReader{err}
+	}
+	return io.NopCloser(xr)
+}
+
+// newLZMAReader decompresses LZMA data as stored in zip files: a 4 byte header with the
+// LZMA SDK version and properties size, followed by the properties and the compressed data.
+func newLZMAReader(r io.Reader) io.ReadCloser {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return &errReader{err}
+	}
+	props := make([]byte, binary.LittleEndian.Uint16(header[2:]))
+	if len(props) != 5 {
+		return &errReader{fmt.Errorf("zip: invalid LZMA properties size %d", len(props))}
+	}
+	if _, err := io.ReadFull(r, props); err != nil {
+		return &errReader{err}
+	}
+	// Rebuild the header of the classic LZMA format, which also holds the uncompressed size.
+	// With a known size the end of stream marker is optional, otherwise it's required.
+	size := ^uint64(0)
+	if sr, ok := r.(*sizedReader); ok {
+		size = sr.size
+	}
+	classic := make([]byte, lzma.HeaderLen)
+	copy(classic, props)
+	binary.LittleEndian.PutUint64(classic[5:], size)
+	lr, err := lzma.NewReader(io.MultiReader(bytes.NewReader(classic), r))
+	if err != nil {
+		return &errReader{err}
+	}
+	return io.NopCloser(lr)
+}
+
+// errReader is returned by decompressors that fail before reading any data.
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read([]byte) (int, error) { return 0, r.err }
+func (r *errReader) Close() error             { return nil }
+
 var (
 	compressors   sync.Map // map[uint16]Compressor
 	decompressors sync.Map // map[uint16]Decompressor
@@ -112,10 +192,15 @@ func init() {
 
 	decompressors.Store(Store, Decompressor(io.NopCloser))
 	decompressors.Store(Deflate, Decompressor(newFlateReader))
+	decompressors.Store(Deflate64, Decompressor(newDeflate64Reader))
+	decompressors.Store(Bzip2, Decompressor(newBzip2Reader))
+	decompressors.Store(LZMA, Decompressor(newLZMAReader))
+	decompressors.Store(Zstd, Decompressor(newZstdReader))
+	decompressors.Store(XZ, Decompressor(newXZReader))
 }
 
 // RegisterDecompressor allows custom decompressors for a specified method ID.
-// The common methods Store and Deflate are built in.
+// The methods Store, Deflate, Deflate64, Bzip2, LZMA, Zstd and XZ are built in.
 func RegisterDecompressor(method uint16, dcomp Decompressor) {
 	if _, dup := decompressors.LoadOrStore(method, dcomp); dup {
 		panic("decompressor already registered")
@@ -0,0 +1,121 @@
+package reader
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// testContent returns the contents of the files in the fixtures of testdata, see make-fixtures.sh.
+func testContent() map[string]string {
+	var b strings.Builder
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&b, "%05d the quick brown fox jumps over the lazy dog %d\n", i, i*i%97)
+	}
+	return map[string]string{"lorem.txt": b.String(), "small.txt": "hello, zip\n"}
+}
+
+// checkEntries reads every entry of the archive, which verifies its CRC-32, and compares it with want.
+func checkEntries(t *testing.T, zr *Reader, method uint16, want map[string]string) {
+	t.Helper()
+	if len(zr.File) != len(want) {
+		t.Fatalf("archive has %d entries, want %d", len(zr.File), len(want))
+	}
+	for _, f := range zr.File {
+		// Info-ZIP stores files that don't compress.
+		if f.Method != method && f.Method != Store {
+			t.Errorf("%s: method = %d, want %d", f.Name, f.Method, method)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Errorf("%s: %v", f.Name, err)
+			continue
+		}
+		if string(b) != want[f.Name] {
+			t.Errorf("%s: contents differ, got %d bytes, want %d bytes", f.Name, len(b), len(want[f.Name]))
+		}
+	}
+}
+
+func TestDecompressors(t *testing.T) {
+	tests := []struct {
+		file   string
+		method uint16
+	}{
+		{"bzip2.zip", Bzip2},
+		{"lzma.zip", LZMA},
+		{"zstd.zip", Zstd},
+		{"xz.zip", XZ},
+	}
+	want := testContent()
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			rc, err := OpenReader(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rc.Close()
+			checkEntries(t, &rc.Reader, tt.method, want)
+		})
+	}
+}
+
+// TestLZMAWithoutEndMarker covers LZMA data without an end of stream marker, as written by 7-Zip,
+// whose end is only known from the uncompressed size in the zip headers.
+func TestLZMAWithoutEndMarker(t *testing.T) {
+	want := testContent()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"lorem.txt", "small.txt"} {
+		data := want[name]
+		var lz bytes.Buffer
+		lw, err := lzma.WriterConfig{SizeInHeader: true, Size: int64(len(data))}.NewWriter(&lz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(lw, data); err != nil {
+			t.Fatal(err)
+		}
+		if err := lw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		// Replace the classic header (properties, dictionary size and uncompressed size)
+		// with the zip one (LZMA SDK version 9.20 and properties size), followed by the properties.
+		classic := lz.Bytes()
+		raw := append([]byte{9, 20, 5, 0}, classic[:5]...)
+		raw = append(raw, classic[lzma.HeaderLen:]...)
+		w, err := zw.CreateRaw(&zip.FileHeader{
+			Name:               name,
+			Method:             LZMA,
+			CRC32:              crc32.ChecksumIEEE([]byte(data)),
+			CompressedSize64:   uint64(len(raw)),
+			UncompressedSize64: uint64(len(data)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(raw); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEntries(t, zr, LZMA, want)
+}
@@ -0,0 +1,508 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// StreamReader reads the files of a zip archive in order from their local file headers,
+// without using the central directory at the end of the archive. This allows reading
+// archives from pipes, and archives whose central directory is missing or damaged.
+//
+// Local file headers hold less information than the central directory: there are no
+// comments or external attributes (so no permissions), and the sizes and checksums of
+// files written with a data descriptor are only known once their data has been read.
+// Such files must be Deflate or Deflate64 compressed, whose data marks its own end,
+// or be followed by a data descriptor with its optional signature, which is searched for.
+type StreamReader struct {
+	src *streamSource
+	z   *Reader // holds options and decompressors
+	cur *streamFile
+	err error // sticky error
+}
+
+// errStreamFile is returned when opening a file of a StreamReader, whose input can't be read at random.
+var errStreamFile = errors.New("zip: files of a StreamReader can only be read with its Read method")
+
+// streamFile is the file currently being read by a StreamReader.
+type streamFile struct {
+	f          *File
+	dataOffset int64
+	raw        io.Reader // raw data, unless inflated directly from the source
+	limited    *io.LimitedReader
+	scanner    *descriptorScanner
+	fr         *inflater
+	dc         io.ReadCloser // decompressor, closed once the file is finished
+	rc         io.Reader     // contents, opened on the first read
+	finished   bool
+}
+
+// NewStreamReader returns a StreamReader reading the archive from r.
+func NewStreamReader(r io.Reader, opts ...Option) *StreamReader {
+	z := new(Reader)
+	for _, opt := range opts {
+		opt(z)
+	}
+	return newStreamReader(r, -1, z)
+}
+
+func newStreamReader(r io.Reader, size int64, z *Reader) *StreamReader {
+	return &StreamReader{
+		src: &streamSource{r: r, br: bufio.NewReaderSize(r, 64<<10), size: size},
+		z:   z,
+	}
+}
+
+// ScanLocalHeaders returns a Reader for the archive in r, which is assumed to have the given size
+// in bytes, built from its local file headers rather than its central directory.
+// Files stored with a data descriptor are read in full to find where they end,
+// other files are skipped over. Reading stops at the first file that can't be read
+// to the end (e.g. because the archive is truncated), which is omitted.
+func ScanLocalHeaders(r io.ReaderAt, size int64, opts ...Option) (*Reader, error) {
+	z := &Reader{r: r}
+	for _, opt := range opts {
+		opt(z)
+	}
+	s := newStreamReader(io.NewSectionReader(r, 0, size), size, z)
+	for {
+		f, err := s.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Keep the files read so far, except for one whose data couldn't be read to the end.
+			if n := len(z.File); n > 0 && !s.cur.finished {
+				z.File = z.File[:n-1]
+			}
+			if len(z.File) == 0 {
+				return nil, err
+			}
+			break
+		}
+		f.zipr = r
+		z.File = append(z.File, f)
+		if err := z.limits.checkEntries(uint64(len(z.File))); err != nil {
+			return nil, err
+		}
+	}
+	if len(z.File) == 0 {
+		return nil, ErrFormat
+	}
+	return z, nil
+}
+
+// Next advances to the next file in the archive, skipping the rest of the current one.
+// It returns io.EOF after the last file, when the central directory or the end of the input is reached.
+// The sizes and checksum of a file written with a data descriptor are set once it has been read.
+func (s *StreamReader) Next() (*FileHeader, error) {
+	f, err := s.next()
+	if err != nil {
+		return nil, err
+	}
+	return &f.FileHeader, nil
+}
+
+// File returns the current file, or nil before the first call to Next.
+// Unlike its FileHeader, it tells the method and encryption of AES encrypted files (see File.CompressionMethod).
+// Its contents can only be read with Read: File.Open returns an error.
+func (s *StreamReader) File() *File {
+	if s.cur == nil {
+		return nil
+	}
+	return s.cur.f
+}
+
+// Read reads the decompressed contents of the current file.
+// The checksum is verified when the end of the file is reached.
+func (s *StreamReader) Read(p []byte) (int, error) {
+	if s.cur == nil {
+		return 0, io.EOF
+	}
+	if s.cur.rc == nil {
+		rc, err := s.open()
+		if err != nil {
+			return 0, err
+		}
+		s.cur.rc = rc
+	}
+	return s.cur.rc.Read(p)
+}
+
+// Offset returns the offset in the archive of the current file's local header.
+func (s *StreamReader) Offset() int64 {
+	if s.cur == nil {
+		return 0
+	}
+	return s.cur.f.headerOffset
+}
+
+func (s *StreamReader) next() (*File, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.cur != nil {
+		if err := s.finish(); err != nil {
+			s.err = err
+			return nil, err
+		}
+	}
+	f, err := s.readLocalHeader()
+	if err != nil {
+		s.err = err
+		return nil, err
+	}
+	cur := &streamFile{f: f, dataOffset: s.src.off}
+	switch {
+	case !f.hasDataDescriptor():
+		cur.limited = &io.LimitedReader{R: s.src, N: int64(f.CompressedSize64)}
+		cur.raw = cur.limited
+	case (f.Method == Deflate || f.Method == Deflate64) && !f.isEncrypted():
+		// The compressed data marks its own end, so decompress it straight from the source.
+		cur.fr = newInflater(s.src, f.Method == Deflate64, nil)
+	default:
+		cur.scanner = &descriptorScanner{src: s.src, zip64: f.zip64}
+		cur.raw = cur.scanner
+	}
+	s.cur = cur
+	return f, nil
+}
+
+// readLocalHeader reads the local file header at the current position.
+func (s *StreamReader) readLocalHeader() (*File, error) {
+	offset := s.src.off
+	var buf [fileHeaderLen]byte
+	if _, err := io.ReadFull(s.src, buf[:4]); err != nil {
+		return nil, err // io.EOF at the end of the input
+	}
+	sig := binary.LittleEndian.Uint32(buf[:4])
+	if sig == dataDescriptorSignature && offset == 0 {
+		// Marker at the start of split archives.
+		return s.readLocalHeader()
+	}
+	switch sig {
+	case fileHeaderSignature:
+	case directoryHeaderSignature, directory64EndSignature, directoryEndSignature:
+		return nil, io.EOF
+	default:
+		return nil, ErrFormat
+	}
+	if _, err := io.ReadFull(s.src, buf[4:]); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	b := readBuf(buf[4:])
+	f := &File{zip: s.z, headerOffset: offset}
+	f.ReaderVersion = b.uint16()
+	f.Flags = b.uint16()
+	f.Method = b.uint16()
+	f.ModifiedTime = b.uint16()
+	f.ModifiedDate = b.uint16()
+	f.CRC32 = b.uint32()
+	f.CompressedSize = b.uint32()
+	f.UncompressedSize = b.uint32()
+	f.CompressedSize64 = uint64(f.CompressedSize)
+	f.UncompressedSize64 = uint64(f.UncompressedSize)
+	filenameLen := int(b.uint16())
+	extraLen := int(b.uint16())
+	d := make([]byte, filenameLen+extraLen)
+	if _, err := io.ReadFull(s.src, d); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	f.Name = string(d[:filenameLen])
+	f.Extra = d[filenameLen:]
+	needUSize := f.UncompressedSize == ^uint32(0)
+	needCSize := f.CompressedSize == ^uint32(0)
+	if err := f.readHeaderExtra(needUSize, needCSize, false); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open returns a reader of the contents of the current file.
+func (s *StreamReader) open() (io.Reader, error) {
+	cur := s.cur
+	f := cur.f
+	if err := s.z.limits.checkOpen(f); err != nil {
+		return nil, err
+	}
+	var rc io.Reader
+	var verify func() error
+	if cur.fr != nil {
+		rc = cur.fr
+	} else {
+		dcomp := s.z.decompressor(f.CompressionMethod())
+		if dcomp == nil {
+			return nil, ErrAlgorithm
+		}
+		raw := cur.raw
+		if f.isEncrypted() {
+			if f.aes != nil && cur.scanner != nil {
+				// The authentication code can't be told apart from the data without knowing its size.
+				return nil, fmt.Errorf("zip: AES encrypted files with a data descriptor can't be streamed: %w", ErrAlgorithm)
+			}
+			var err error
+			if raw, err = f.decrypt(raw); err != nil {
+				return nil, err
+			}
+			if v, ok := raw.(interface{ verify() error }); ok {
+				verify = v.verify
+			}
+		}
+		if cur.limited != nil {
+			// The uncompressed size is only known up front without a data descriptor.
+			raw = &sizedReader{Reader: raw, size: f.UncompressedSize64}
+		}
+		cur.dc = dcomp(raw)
+		rc = cur.dc
+	}
+	return &checksumReader{
+		// Finish reading the raw data (and data descriptor) before the checksum is compared.
+		rc:   &finishReader{r: rc, verify: verify, finish: s.finish},
+		hash: crc32.NewIEEE(),
+		f:    f,
+	}, nil
+}
+
+// finish reads the rest of the current file's data and its data descriptor,
+// setting the file's sizes and checksum from the descriptor.
+func (s *StreamReader) finish() error {
+	cur := s.cur
+	if cur.finished {
+		return nil
+	}
+	f := cur.f
+	switch {
+	case cur.limited != nil:
+		if err := s.src.skip(cur.limited.N); err != nil {
+			return err
+		}
+		cur.limited.N = 0
+	case cur.fr != nil:
+		if _, err := io.Copy(io.Discard, cur.fr); err != nil {
+			return err
+		}
+		s.src.unread(cur.fr.unused())
+		size := uint64(s.src.off - cur.dataOffset)
+		dd, err := readDataDescriptor(s.src, f.zip64)
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if dd.compressedSize != size || dd.uncompressedSize != uint64(cur.fr.out) {
+			return fmt.Errorf("zip: data descriptor does not match data (name: %s): %w", f.Name, ErrFormat)
+		}
+		f.CRC32 = dd.crc32
+		f.CompressedSize64 = dd.compressedSize
+		f.UncompressedSize64 = dd.uncompressedSize
+	case cur.scanner != nil:
+		if _, err := io.Copy(io.Discard, cur.scanner); err != nil {
+			return err
+		}
+		f.CRC32 = cur.scanner.desc.crc32
+		f.CompressedSize64 = cur.scanner.desc.compressedSize
+		f.UncompressedSize64 = cur.scanner.desc.uncompressedSize
+	}
+	f.CompressedSize = uint32(min64(f.CompressedSize64, uint32max))
+	f.UncompressedSize = uint32(min64(f.UncompressedSize64, uint32max))
+	if cur.dc != nil {
+		cur.dc.Close()
+	}
+	cur.finished = true
+	return nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// finishReader calls verify (if set) and then finish when r reaches EOF.
+// The authentication code checked by verify must be read before finish skips past it.
+type finishReader struct {
+	r      io.Reader
+	verify func() error
+	finish func() error
+}
+
+func (r *finishReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err == io.EOF {
+		if r.verify != nil {
+			if verr := r.verify(); verr != nil {
+				return n, verr
+			}
+		}
+		if ferr := r.finish(); ferr != nil {
+			return n, ferr
+		}
+	}
+	return n, err
+}
+
+func (r *finishReader) Close() error { return nil }
+
+// streamSource reads an archive sequentially, tracking the offset
+// and allowing bytes that were read ahead to be pushed back.
+type streamSource struct {
+	r       io.Reader
+	br      *bufio.Reader
+	size    int64 // size of the input, or -1 if unknown
+	pending []byte
+	off     int64 // offset of the next byte
+}
+
+func (s *streamSource) Read(p []byte) (int, error) {
+	if len(s.pending) > 0 {
+		n := copy(p, s.pending)
+		s.pending = s.pending[n:]
+		s.off += int64(n)
+		return n, nil
+	}
+	n, err := s.br.Read(p)
+	s.off += int64(n)
+	return n, err
+}
+
+func (s *streamSource) ReadByte() (byte, error) {
+	if len(s.pending) > 0 {
+		b := s.pending[0]
+		s.pending = s.pending[1:]
+		s.off++
+		return b, nil
+	}
+	b, err := s.br.ReadByte()
+	if err == nil {
+		s.off++
+	}
+	return b, err
+}
+
+// unread pushes back bytes that were read ahead.
+func (s *streamSource) unread(b []byte) {
+	s.pending = append(append([]byte{}, b...), s.pending...)
+	s.off -= int64(len(b))
+}
+
+// skip discards n bytes, seeking over them if the input allows it.
+func (s *streamSource) skip(n int64) error {
+	if s.size >= 0 && s.off+n > s.size {
+		return io.ErrUnexpectedEOF
+	}
+	p := n
+	if int64(len(s.pending)) < p {
+		p = int64(len(s.pending))
+	}
+	s.pending = s.pending[p:]
+	s.off += p
+	n -= p
+	if seeker, ok := s.r.(io.Seeker); ok && n > int64(s.br.Buffered()) {
+		n -= int64(s.br.Buffered())
+		s.off += int64(s.br.Buffered())
+		if _, err := seeker.Seek(n, io.SeekCurrent); err != nil {
+			return err
+		}
+		s.br.Reset(s.r)
+		s.off += n
+		return nil
+	}
+	m, err := io.CopyN(io.Discard, s, n)
+	if m < n {
+		return unexpectedEOF(err)
+	}
+	return nil
+}
+
+// descriptorScanner reads raw file data of unknown size, which ends at a data descriptor
+// with a signature and a compressed size matching the number of bytes read.
+type descriptorScanner struct {
+	src   *streamSource
+	zip64 bool
+	buf   []byte // bytes read ahead from src, not yet returned
+	n     int64  // bytes returned so far
+	desc  *dataDescriptor
+	eof   bool
+}
+
+var dataDescriptorSig = []byte{'P', 'K', 0x07, 0x08}
+
+func (d *descriptorScanner) Read(p []byte) (int, error) {
+	for {
+		if d.desc != nil {
+			// d.buf now only holds the data preceding the descriptor.
+			if len(d.buf) == 0 {
+				return 0, io.EOF
+			}
+			n := copy(p, d.buf)
+			d.buf = d.buf[n:]
+			return n, nil
+		}
+		if safe := d.scan(); d.desc == nil && safe > 0 {
+			n := copy(p, d.buf[:safe])
+			d.buf = d.buf[n:]
+			d.n += int64(n)
+			return n, nil
+		}
+		if d.desc != nil {
+			continue
+		}
+		if d.eof {
+			return 0, io.ErrUnexpectedEOF
+		}
+		if err := d.fill(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (d *descriptorScanner) fill() error {
+	chunk := make([]byte, 32<<10)
+	n, err := d.src.Read(chunk)
+	d.buf = append(d.buf, chunk[:n]...)
+	if err == io.EOF {
+		d.eof = true
+		return nil
+	}
+	return err
+}
+
+// scan looks for the data descriptor in d.buf. If it is found, d.desc is set,
+// d.buf is truncated to the data preceding it and any bytes following it are pushed back.
+// Otherwise it returns the number of bytes at the start of d.buf that can't be part of the descriptor.
+func (d *descriptorScanner) scan() int {
+	descLen := dataDescriptorLen
+	if d.zip64 {
+		descLen = dataDescriptor64Len
+	}
+	for i := 0; ; i++ {
+		j := bytes.Index(d.buf[i:], dataDescriptorSig)
+		if j < 0 {
+			// The last bytes may be the start of a signature.
+			if safe := len(d.buf) - len(dataDescriptorSig) + 1; safe > 0 {
+				return safe
+			}
+			return 0
+		}
+		i += j
+		if len(d.buf)-i < descLen {
+			return i // need more data to check this candidate
+		}
+		b := readBuf(d.buf[i+4 : i+descLen])
+		desc := &dataDescriptor{crc32: b.uint32()}
+		if d.zip64 {
+			desc.compressedSize, desc.uncompressedSize = b.uint64(), b.uint64()
+		} else {
+			desc.compressedSize, desc.uncompressedSize = uint64(b.uint32()), uint64(b.uint32())
+		}
+		if desc.compressedSize == uint64(d.n)+uint64(i) {
+			d.desc = desc
+			d.src.unread(d.buf[i+descLen:])
+			d.buf = d.buf[:i]
+			return i
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package reader
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+)
+
+// streamEntry is a file of the archive made by newStreamArchive.
+type streamEntry struct {
+	name       string
+	method     uint16
+	descriptor bool
+	data       string
+}
+
+var streamEntries = []streamEntry{
+	{"deflated.txt", Deflate, true, strings.Repeat("deflated with a data descriptor\n", 100)},
+	// The stored data holds a data descriptor signature that isn't followed by a matching descriptor.
+	{"stored.txt", Store, true, "stored with a data descriptor, PK\x07\x08 and more"},
+	{"raw.txt", Store, false, "stored with its sizes in the local header"},
+	{"dir/", Store, false, ""},
+}
+
+// newStreamArchive returns an archive of streamEntries.
+func newStreamArchive(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range streamEntries {
+		var w io.Writer
+		var err error
+		if e.descriptor {
+			w, err = zw.CreateHeader(&zip.FileHeader{Name: e.name, Method: e.method})
+		} else {
+			w, err = zw.CreateRaw(&zip.FileHeader{
+				Name:               e.name,
+				Method:             e.method,
+				CRC32:              crc32.ChecksumIEEE([]byte(e.data)),
+				CompressedSize64:   uint64(len(e.data)),
+				UncompressedSize64: uint64(len(e.data)),
+			})
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, e.data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// nonSeekable hides the other methods of a reader, such as io.Seeker.
+type nonSeekable struct {
+	io.Reader
+}
+
+func TestStreamReader(t *testing.T) {
+	archive := newStreamArchive(t)
+	zr, err := NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sr := NewStreamReader(nonSeekable{bytes.NewReader(archive)})
+	for i, want := range streamEntries {
+		h, err := sr.Next()
+		if err != nil {
+			t.Fatalf("Next() = %v, want %s", err, want.name)
+		}
+		if h.Name != want.name || h.Method != want.method || (h.Flags&0x8 != 0) != want.descriptor {
+			t.Errorf("Next() = %s (method %d, flags %#x), want %+v", h.Name, h.Method, h.Flags, want)
+		}
+		if f := sr.File(); f == nil || &f.FileHeader != h || sr.Offset() != zr.File[i].HeaderOffset() {
+			t.Errorf("%s: File() and Offset() don't match the current file", want.name)
+		}
+		b, err := io.ReadAll(sr)
+		if err != nil || string(b) != want.data {
+			t.Errorf("%s = %q, %v, want %q", want.name, b, err, want.data)
+		}
+		// The sizes and checksum of files with a data descriptor are set once they have been read.
+		central := zr.File[i]
+		if h.CRC32 != central.CRC32 || h.CompressedSize64 != central.CompressedSize64 || h.UncompressedSize64 != central.UncompressedSize64 {
+			t.Errorf("%s: CRC-32 and sizes %08x, %d, %d, want %08x, %d, %d", want.name, h.CRC32, h.CompressedSize64,
+				h.UncompressedSize64, central.CRC32, central.CompressedSize64, central.UncompressedSize64)
+		}
+		if _, err := sr.File().Open(); err == nil {
+			t.Errorf("%s: Open() of a streamed file: no error", want.name)
+		}
+	}
+	if _, err := sr.Next(); err != io.EOF {
+		t.Errorf("Next() at the central directory = %v, want %v", err, io.EOF)
+	}
+}
+
+func TestStreamReaderSkip(t *testing.T) {
+	// Files that aren't read are skipped, including those whose end is only known from their data descriptor.
+	sr := NewStreamReader(nonSeekable{bytes.NewReader(newStreamArchive(t))})
+	for _, want := range streamEntries {
+		h, err := sr.Next()
+		if err != nil || h.Name != want.name {
+			t.Fatalf("Next() = %v, %v, want %s", h, err, want.name)
+		}
+		if want.descriptor {
+			continue
+		}
+		if b, err := io.ReadAll(sr); err != nil || string(b) != want.data {
+			t.Errorf("%s = %q, %v, want %q", want.name, b, err, want.data)
+		}
+	}
+	if _, err := sr.Next(); err != io.EOF {
+		t.Errorf("Next() at the central directory = %v, want %v", err, io.EOF)
+	}
+}
+
+func TestStreamReaderTruncated(t *testing.T) {
+	archive := newStreamArchive(t)
+	zr, err := NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, f := range zr.File[:len(zr.File)-1] {
+		// Cut the archive in the middle of the file's local header, and of its data.
+		dataOffset, err := f.DataOffset()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, size := range []int64{f.HeaderOffset() + 10, dataOffset + int64(f.CompressedSize64)/2} {
+			sr := NewStreamReader(nonSeekable{bytes.NewReader(archive[:size])})
+			var err error
+			for j := 0; err == nil; j++ {
+				if _, err = sr.Next(); err == nil {
+					_, err = io.ReadAll(sr)
+				}
+				if j > i {
+					t.Fatalf("%s cut at %d: read past the truncated file", f.Name, size)
+				}
+			}
+			if !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Errorf("%s cut at %d: error = %v, want %v", streamEntries[i].name, size, err, io.ErrUnexpectedEOF)
+			}
+		}
+	}
+}
@@ -27,8 +27,14 @@ import (
 
 // Compression methods.
 const (
-	Store   uint16 = 0 // no compression
-	Deflate uint16 = 8 // DEFLATE compressed
+	Store     uint16 = 0  // no compression
+	Deflate   uint16 = 8  // DEFLATE compressed
+	Deflate64 uint16 = 9  // Deflate64 (enhanced deflate) compressed
+	Bzip2     uint16 = 12 // bzip2 compressed
+	LZMA      uint16 = 14 // LZMA compressed
+	Zstd      uint16 = 93 // Zstandard compressed
+	XZ        uint16 = 95 // XZ compressed
+	AES       uint16 = 99 // WinZip AES encrypted, the compression method is stored in the AES extra field
 )
 
 const (
@@ -75,6 +81,7 @@ const (
 	unixExtraID        = 0x000d // UNIX
 	extTimeExtraID     = 0x5455 // Extended timestamp
 	infoZipUnixExtraID = 0x5855 // Info-ZIP Unix extension
+	aesExtraID         = 0x9901 // WinZip AES encryption
 )
 
 // FileHeader describes a file within a zip file.
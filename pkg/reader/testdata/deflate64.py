@@ -0,0 +1,243 @@
+#!/usr/bin/env python3
+"""Compresses a file with Deflate64 for the fixtures of the inflater tests.
+
+No common tool but 7-Zip writes Deflate64, so this is a minimal encoder: greedy LZ77
+over the 64 KiB window, emitting a stored block, a block with the fixed Huffman codes
+and a block with dynamic Huffman codes, so that all block types use the Deflate64
+distance codes 30 and 31 and the length code 285 with 16 extra bits.
+
+Usage: deflate64.py <input> <output>
+"""
+
+import heapq
+import sys
+
+WINDOW = 1 << 16
+MIN_MATCH, MAX_MATCH = 3, 65538
+MAX_CHAIN = 64
+
+LENGTH_BASE = [3, 4, 5, 6, 7, 8, 9, 10, 11, 13, 15, 17, 19, 23, 27, 31, 35, 43, 51, 59, 67, 83, 99, 115, 131, 163, 195, 227]
+LENGTH_EXTRA = [0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 2, 2, 3, 3, 3, 3, 4, 4, 4, 4, 5, 5, 5, 5]
+DIST_BASE = [1, 2, 3, 4, 5, 7, 9, 13, 17, 25, 33, 49, 65, 97, 129, 193, 257, 385, 513, 769, 1025, 1537, 2049, 3073,
+             4097, 6145, 8193, 12289, 16385, 24577, 32769, 49153]
+DIST_EXTRA = [0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6, 7, 7, 8, 8, 9, 9, 10, 10, 11, 11, 12, 12, 13, 13, 14, 14]
+CODE_LENGTH_ORDER = [16, 17, 18, 0, 8, 7, 9, 6, 10, 5, 11, 4, 12, 3, 13, 2, 14, 1, 15]
+
+
+class BitWriter:
+    def __init__(self):
+        self.out = bytearray()
+        self.bits = 0
+        self.nbits = 0
+
+    def write(self, value, n):
+        self.bits |= value << self.nbits
+        self.nbits += n
+        while self.nbits >= 8:
+            self.out.append(self.bits & 0xFF)
+            self.bits >>= 8
+            self.nbits -= 8
+
+    def write_code(self, code, n):
+        """Writes a Huffman code, which is stored most significant bit first."""
+        self.write(int(format(code, "0%db" % n)[::-1], 2), n)
+
+    def align(self):
+        if self.nbits > 0:
+            self.write(0, 8 - self.nbits)
+
+    def bytes(self):
+        self.align()
+        return bytes(self.out)
+
+
+def tokens(data, start, end):
+    """Returns the literals (ints) and matches ((length, distance)) of data[start:end], with data[:start] as history."""
+    head, prev, result = {}, {}, []
+
+    def insert(i):
+        if i + MIN_MATCH <= len(data):
+            key = data[i:i + MIN_MATCH]
+            if key in head:
+                prev[i] = head[key]
+            head[key] = i
+
+    for i in range(max(0, start - WINDOW), start):
+        insert(i)
+    i = start
+    while i < end:
+        best_len, best_dist = 0, 0
+        candidate, chain = head.get(data[i:i + MIN_MATCH]), 0
+        limit = min(MAX_MATCH, end - i)
+        while candidate is not None and i - candidate <= WINDOW and chain < MAX_CHAIN:
+            n = 0
+            while n < limit and data[candidate + n] == data[i + n]:
+                n += 1
+            if n > best_len:
+                best_len, best_dist = n, i - candidate
+                if n == limit:
+                    break
+            candidate, chain = prev.get(candidate), chain + 1
+        if best_len >= MIN_MATCH:
+            result.append((best_len, best_dist))
+            for j in range(i, i + best_len):
+                insert(j)
+            i += best_len
+        else:
+            result.append(data[i])
+            insert(i)
+            i += 1
+    return result
+
+
+def length_code(length):
+    """Returns the symbol, extra bits and their count of a match length."""
+    if length > 258:
+        return 285, length - 3, 16
+    code = max(c for c in range(len(LENGTH_BASE)) if LENGTH_BASE[c] <= length)
+    return 257 + code, length - LENGTH_BASE[code], LENGTH_EXTRA[code]
+
+
+def dist_code(dist):
+    code = max(c for c in range(len(DIST_BASE)) if DIST_BASE[c] <= dist)
+    return code, dist - DIST_BASE[code], DIST_EXTRA[code]
+
+
+def code_lengths(freqs, limit):
+    """Returns optimal code lengths of at most limit bits for the frequencies (package-merge)."""
+    symbols = sorted((f, s) for s, f in enumerate(freqs) if f > 0)
+    lengths = [0] * len(freqs)
+    if len(symbols) == 1:
+        lengths[symbols[0][1]] = 1
+        return lengths
+    leaves = [(f, [s]) for f, s in symbols]
+    packages = leaves
+    for _ in range(limit - 1):
+        merged = [(packages[i][0] + packages[i + 1][0], packages[i][1] + packages[i + 1][1])
+                  for i in range(0, len(packages) - 1, 2)]
+        packages = list(heapq.merge(leaves, merged, key=lambda p: p[0]))
+    for _, syms in packages[:2 * len(symbols) - 2]:
+        for s in syms:
+            lengths[s] += 1
+    return lengths
+
+
+def canonical_codes(lengths):
+    codes, code = [0] * len(lengths), 0
+    for n in range(1, max(lengths) + 1):
+        for s, l in enumerate(lengths):
+            if l == n:
+                codes[s] = code
+                code += 1
+        code <<= 1
+    return codes
+
+
+def fixed_lengths():
+    return [8] * 144 + [9] * 112 + [7] * 24 + [8] * 8, [5] * 32
+
+
+def write_tokens(w, toks, lit_lengths, dist_lengths):
+    lit_codes, dist_codes = canonical_codes(lit_lengths), canonical_codes(dist_lengths)
+    for t in toks:
+        if isinstance(t, int):
+            w.write_code(lit_codes[t], lit_lengths[t])
+            continue
+        sym, extra, n = length_code(t[0])
+        w.write_code(lit_codes[sym], lit_lengths[sym])
+        w.write(extra, n)
+        sym, extra, n = dist_code(t[1])
+        w.write_code(dist_codes[sym], dist_lengths[sym])
+        w.write(extra, n)
+    w.write_code(lit_codes[256], lit_lengths[256])
+
+
+def write_stored(w, data, final):
+    w.write(final, 1)
+    w.write(0, 2)
+    w.align()
+    w.write(len(data), 16)
+    w.write(len(data) ^ 0xFFFF, 16)
+    for b in data:
+        w.write(b, 8)
+
+
+def write_fixed(w, toks, final):
+    w.write(final, 1)
+    w.write(1, 2)
+    write_tokens(w, toks, *fixed_lengths())
+
+
+def write_dynamic(w, toks, final):
+    lit_freqs, dist_freqs = [0] * 286, [0] * 32
+    lit_freqs[256] = 1
+    for t in toks:
+        if isinstance(t, int):
+            lit_freqs[t] += 1
+        else:
+            lit_freqs[length_code(t[0])[0]] += 1
+            dist_freqs[dist_code(t[1])[0]] += 1
+    if not any(dist_freqs):
+        dist_freqs[0] = 1
+    lit_lengths, dist_lengths = code_lengths(lit_freqs, 15), code_lengths(dist_freqs, 15)
+    nlit = max(257, max(s for s, l in enumerate(lit_lengths) if l) + 1)
+    ndist = max(1, max(s for s, l in enumerate(dist_lengths) if l) + 1)
+
+    # Run-length encode the code lengths with the symbols 16 (repeat previous), 17 and 18 (repeat zero).
+    all_lengths, runs, i = lit_lengths[:nlit] + dist_lengths[:ndist], [], 0
+    while i < len(all_lengths):
+        l, n = all_lengths[i], 1
+        while i + n < len(all_lengths) and all_lengths[i + n] == l:
+            n += 1
+        i += n
+        if l == 0:
+            while n >= 11:
+                runs.append((18, min(n, 138) - 11, 7))
+                n -= min(n, 138)
+            if n >= 3:
+                runs.append((17, n - 3, 3))
+                n = 0
+        else:
+            runs.append((l, 0, 0))
+            n -= 1
+            while n >= 3:
+                runs.append((16, min(n, 6) - 3, 2))
+                n -= min(n, 6)
+        runs.extend((l, 0, 0) for _ in range(n))
+    clen_freqs = [0] * 19
+    for sym, _, _ in runs:
+        clen_freqs[sym] += 1
+    clen_lengths = code_lengths(clen_freqs, 7)
+    nclen = max(4, max(i + 1 for i, s in enumerate(CODE_LENGTH_ORDER) if clen_lengths[s]))
+    clen_codes = canonical_codes(clen_lengths)
+
+    w.write(final, 1)
+    w.write(2, 2)
+    w.write(nlit - 257, 5)
+    w.write(ndist - 1, 5)
+    w.write(nclen - 4, 4)
+    for s in CODE_LENGTH_ORDER[:nclen]:
+        w.write(clen_lengths[s], 3)
+    for sym, extra, n in runs:
+        w.write_code(clen_codes[sym], clen_lengths[sym])
+        w.write(extra, n)
+    write_tokens(w, toks, lit_lengths, dist_lengths)
+
+
+def compress(data):
+    w = BitWriter()
+    if len(data) < 2048:
+        write_fixed(w, tokens(data, 0, len(data)), 1)
+        return w.bytes()
+    stored, middle = 1024, len(data) // 3
+    write_stored(w, data[:stored], 0)
+    write_fixed(w, tokens(data, stored, middle), 0)
+    write_dynamic(w, tokens(data, middle, len(data)), 1)
+    return w.bytes()
+
+
+if __name__ == "__main__":
+    with open(sys.argv[1], "rb") as f:
+        data = f.read()
+    with open(sys.argv[2], "wb") as f:
+        f.write(compress(data))
@@ -0,0 +1,200 @@
+#!/bin/sh
+# Generates the fixture archives of the decompressor tests with independent implementations:
+# Info-ZIP for bzip2, CPython's zipfile for LZMA, and the zstd and xz command line tools for
+# Zstandard and XZ, whose output is wrapped in a zip container by hand (no common zip tool writes them).
+#
+# Deflate64 is compressed by deflate64.py. If ZLIB_SRC points to a zlib source tree, its output is
+# checked with the reference Deflate64 decoder in contrib/infback9 before it is written.
+#
+# The encrypted archives use the password "secret". Info-ZIP encrypts zipcrypto.zip, which always has
+# data descriptors. zipcrypto-crc.zip (without data descriptors) and aes.zip (WinZip AES-128 and AES-256,
+# AE-1 and AE-2) are encrypted by hand, with AES from the openssl command line tool.
+set -eu
+cd "$(dirname "$0")"
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+
+# The files must match testContent in register_test.go and deflate64Content in inflate_test.go.
+python3 - "$tmp" <<'PY'
+import sys
+lorem = "".join("%05d the quick brown fox jumps over the lazy dog %d\n" % (i, i * i % 97) for i in range(2000)).encode()
+with open(sys.argv[1] + "/lorem.txt", "wb") as f:
+    f.write(lorem)
+with open(sys.argv[1] + "/small.txt", "wb") as f:
+    f.write(b"hello, zip\n")
+
+# Random data repeated at distances that need the Deflate64 distance codes 30 and 31,
+# followed by a run longer than a Deflate64 match.
+x, rnd = 1, bytearray()
+for _ in range(40000):
+    x = (x * 1103515245 + 12345) & 0x7FFFFFFF
+    rnd.append(x >> 16 & 0xFF)
+text = lorem[:20000]
+with open(sys.argv[1] + "/window.bin", "wb") as f:
+    f.write(rnd + text + rnd[:20000] + text + b"z" * 70000)
+PY
+
+# Fixed modification times keep the archives reproducible.
+touch -t 202201220000 "$tmp"/*
+
+rm -f bzip2.zip lzma.zip zstd.zip xz.zip deflate64.zip zipcrypto.zip zipcrypto-crc.zip aes.zip
+(cd "$tmp" && zip -q -X -Z bzip2 bzip2.zip lorem.txt small.txt) && mv "$tmp/bzip2.zip" .
+(cd "$tmp" && zip -q -X -P secret zipcrypto.zip lorem.txt small.txt) && mv "$tmp/zipcrypto.zip" .
+
+python3 - "$tmp" <<'PY'
+import sys, zipfile
+with zipfile.ZipFile("lzma.zip", "w", zipfile.ZIP_LZMA) as z:
+    for name in ("lorem.txt", "small.txt"):
+        z.write(sys.argv[1] + "/" + name, name)
+PY
+
+for name in lorem.txt small.txt window.bin; do
+    zstd -q -19 -c "$tmp/$name" > "$tmp/$name.zst"
+    xz -q -9 -c "$tmp/$name" > "$tmp/$name.xz"
+    python3 deflate64.py "$tmp/$name" "$tmp/$name.deflate64"
+done
+
+if [ -n "${ZLIB_SRC:-}" ]; then
+    cat > "$tmp/inflate9.c" <<'EOF'
+#include <stdio.h>
+#include <stdlib.h>
+#include "zlib.h"
+#include "infback9.h"
+
+static unsigned char in[1 << 20];
+
+static unsigned get(void *desc, z_const unsigned char **buf) {
+    *buf = in;
+    return fread(in, 1, sizeof(in), stdin);
+}
+
+static int put(void *desc, unsigned char *buf, unsigned len) {
+    return fwrite(buf, 1, len, stdout) != len;
+}
+
+int main(void) {
+    static unsigned char window[65536];
+    z_stream strm = {0};
+    if (inflateBack9Init(&strm, window) != Z_OK) return 1;
+    int ret = inflateBack9(&strm, get, NULL, put, NULL);
+    inflateBack9End(&strm);
+    return ret != Z_STREAM_END;
+}
+EOF
+    cc -o "$tmp/inflate9" -I"$ZLIB_SRC" -I"$ZLIB_SRC/contrib/infback9" "$tmp/inflate9.c" \
+        "$ZLIB_SRC/contrib/infback9/infback9.c" "$ZLIB_SRC/contrib/infback9/inftree9.c" "$ZLIB_SRC/zutil.c"
+    for name in lorem.txt small.txt window.bin; do
+        "$tmp/inflate9" < "$tmp/$name.deflate64" | cmp - "$tmp/$name"
+    done
+fi
+
+python3 - "$tmp" <<'PY'
+import struct, sys, zlib
+
+def write_zip(path, method, entries):
+    """Writes a zip file of (name, raw data, compressed data) entries using the given method."""
+    out, central = bytearray(), bytearray()
+    for name, raw, comp in entries:
+        crc, offset, n = zlib.crc32(raw), len(out), name.encode()
+        # version needed 6.3, no flags, DOS time 2022-01-22 00:00
+        fields = struct.pack("<HHHHHIII", 63, 0, method, 0, 0x5436, crc, len(comp), len(raw))
+        out += b"PK\x03\x04" + fields + struct.pack("<HH", len(n), 0) + n + comp
+        central += b"PK\x01\x02" + struct.pack("<H", 63) + fields + struct.pack("<HHHHHII", len(n), 0, 0, 0, 0, 0o100644 << 16, offset) + n
+    end = struct.pack("<4sHHHHIIH", b"PK\x05\x06", 0, 0, len(entries), len(entries), len(central), len(out), 0)
+    with open(path, "wb") as f:
+        f.write(out + central + end)
+
+def read(name):
+    with open(sys.argv[1] + "/" + name, "rb") as f:
+        return f.read()
+
+for path, method, ext, names in (
+    ("zstd.zip", 93, ".zst", ("lorem.txt", "small.txt")),
+    ("xz.zip", 95, ".xz", ("lorem.txt", "small.txt")),
+    ("deflate64.zip", 9, ".deflate64", ("lorem.txt", "small.txt", "window.bin")),
+):
+    write_zip(path, method, [(name, read(name), read(name + ext)) for name in names])
+PY
+
+python3 - "$tmp" <<'PY'
+import hashlib, hmac, struct, subprocess, sys, zipfile, zlib
+
+PASSWORD = b"secret"
+DOS_TIME, DOS_DATE = 0, 0x5436  # 2022-01-22 00:00
+
+def read(name):
+    with open(sys.argv[1] + "/" + name, "rb") as f:
+        return f.read()
+
+def deflate(raw):
+    c = zlib.compressobj(9, zlib.DEFLATED, -15)
+    return c.compress(raw) + c.flush()
+
+def write_zip(path, entries):
+    """Writes a zip file of (name, method, flags, crc, uncompressed size, extra, data) entries."""
+    out, central = bytearray(), bytearray()
+    for name, method, flags, crc, size, extra, data in entries:
+        offset, n = len(out), name.encode()
+        fields = struct.pack("<HHHHHIII", 51, flags, method, DOS_TIME, DOS_DATE, crc, len(data), size)
+        out += b"PK\x03\x04" + fields + struct.pack("<HH", len(n), len(extra)) + n + extra + data
+        central += b"PK\x01\x02" + struct.pack("<H", 63) + fields
+        central += struct.pack("<HHHHHII", len(n), len(extra), 0, 0, 0, 0o100644 << 16, offset) + n + extra
+    end = struct.pack("<4sHHHHIIH", b"PK\x05\x06", 0, 0, len(entries), len(entries), len(central), len(out), 0)
+    with open(path, "wb") as f:
+        f.write(out + central + end)
+
+def crc32_update(crc, b):
+    return zlib.crc32(bytes([b]), crc ^ 0xFFFFFFFF) ^ 0xFFFFFFFF
+
+def zipcrypto_encrypt(data, check):
+    """Encrypts data with the traditional PKWARE encryption, after a header ending with the check byte."""
+    keys = [0x12345678, 0x23456789, 0x34567890]
+    def update(b):
+        keys[0] = crc32_update(keys[0], b)
+        keys[1] = ((keys[1] + (keys[0] & 0xFF)) * 134775813 + 1) & 0xFFFFFFFF
+        keys[2] = crc32_update(keys[2], keys[1] >> 24)
+    for b in PASSWORD:
+        update(b)
+    out = bytearray()
+    for b in bytes(range(11)) + bytes([check]) + data:
+        t = keys[2] | 2
+        out.append(b ^ ((t * (t ^ 1)) >> 8) & 0xFF)
+        update(b)
+    return bytes(out)
+
+entries = []
+for name, method in (("lorem.txt", 8), ("small.txt", 0)):
+    raw = read(name)
+    comp = deflate(raw) if method == 8 else raw
+    crc = zlib.crc32(raw)
+    entries.append((name, method, 1, crc, len(raw), b"", zipcrypto_encrypt(comp, crc >> 24)))
+write_zip("zipcrypto-crc.zip", entries)
+with zipfile.ZipFile("zipcrypto-crc.zip") as z:
+    for name in ("lorem.txt", "small.txt"):
+        assert z.read(name, pwd=PASSWORD) == read(name)
+
+def aes_encrypt(data, strength, salt):
+    """Encrypts data as WinZip AES: salt, password verifier, AES-CTR data and HMAC-SHA1 authentication code."""
+    key_len = 8 + 8 * strength
+    keys = hashlib.pbkdf2_hmac("sha1", PASSWORD, salt, 1000, 2 * key_len + 2)
+    # The counter is little-endian and starts at 1. The key stream is made with AES-ECB.
+    blocks = b"".join(struct.pack("<Q", i) + bytes(8) for i in range(1, len(data) // 16 + 2))
+    stream = subprocess.run(["openssl", "enc", "-aes-%d-ecb" % (key_len * 8), "-nopad", "-K", keys[:key_len].hex()],
+                            input=blocks, stdout=subprocess.PIPE, check=True).stdout
+    encrypted = bytes(a ^ b for a, b in zip(data, stream))
+    code = hmac.new(keys[key_len:2 * key_len], encrypted, hashlib.sha1).digest()[:10]
+    return salt + keys[2 * key_len:] + encrypted + code
+
+entries = []
+for version, strength in ((1, 1), (2, 1), (1, 3), (2, 3)):
+    prefix = "ae%d-aes%d/" % (version, 64 + 64 * strength)
+    for name, method in (("lorem.txt", 8), ("small.txt", 0)):
+        raw = read(name)
+        comp = deflate(raw) if method == 8 else raw
+        salt = bytes((len(entries) * 16 + i) & 0xFF for i in range(4 + 4 * strength))
+        extra = struct.pack("<HHH2sBH", 0x9901, 7, version, b"AE", strength, method)
+        # AE-2 omits the CRC-32, which is replaced by the authentication code.
+        crc = zlib.crc32(raw) if version == 1 else 0
+        entries.append((prefix + name, 99, 1, crc, len(raw), extra, aes_encrypt(comp, strength, salt)))
+write_zip("aes.zip", entries)
+PY
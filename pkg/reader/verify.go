@@ -0,0 +1,184 @@
+package reader
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Kinds of problems found by Reader.VerifyHeaders and File.Verify.
+const (
+	ProblemDirectory      = "central_directory" // the central directory can't be read
+	ProblemLocalHeader    = "local_header"      // the local file header is missing or doesn't match the central directory
+	ProblemDataDescriptor = "data_descriptor"   // the data descriptor is missing or doesn't match the central directory
+	ProblemOverlap        = "overlap"           // the file overlaps another file or the central directory
+	ProblemGap            = "gap"               // bytes that don't belong to any file precede the file or the central directory
+	ProblemContents       = "contents"          // the contents can't be decompressed or don't match the checksum
+)
+
+// A Problem is an inconsistency found in an archive.
+type Problem struct {
+	Kind    string
+	Name    string // name of the file concerned, empty for the central directory
+	Offset  int64  // offset in the archive where the problem lies
+	Message string
+}
+
+// VerifyHeaders checks the structure of the archive without reading the contents of its files:
+// each file's local header and data descriptor must match its central directory entry,
+// and the files must follow each other without overlapping or leaving gaps before the central directory.
+func (z *Reader) VerifyHeaders() []Problem {
+	type extent struct {
+		f          *File
+		start, end int64
+	}
+	var problems []Problem
+	var extents []extent
+	for _, f := range z.File {
+		end, fp := f.verifyHeaders()
+		problems = append(problems, fp...)
+		if end >= 0 {
+			extents = append(extents, extent{f: f, start: f.headerOffset, end: end})
+		}
+	}
+	sort.SliceStable(extents, func(i, j int) bool {
+		return extents[i].start < extents[j].start
+	})
+
+	var pos int64 // end of the furthest file so far
+	var last *File
+	for i := range extents {
+		e := &extents[i]
+		switch {
+		case e.start < pos:
+			problems = append(problems, Problem{
+				Kind:    ProblemOverlap,
+				Name:    e.f.Name,
+				Offset:  e.start,
+				Message: fmt.Sprintf("file overlaps %d bytes of another file (name: %s)", pos-e.start, last.Name),
+			})
+		case e.start > pos:
+			problems = append(problems, Problem{
+				Kind:    ProblemGap,
+				Name:    e.f.Name,
+				Offset:  pos,
+				Message: fmt.Sprintf("%d bytes precede the file that don't belong to any file", e.start-pos),
+			})
+		}
+		if e.end > pos {
+			pos, last = e.end, e.f
+		}
+	}
+	if z.directoryOffset > 0 {
+		switch {
+		case pos > z.directoryOffset:
+			problems = append(problems, Problem{
+				Kind:    ProblemOverlap,
+				Name:    last.Name,
+				Offset:  z.directoryOffset,
+				Message: fmt.Sprintf("file overlaps %d bytes of the central directory", pos-z.directoryOffset),
+			})
+		case pos < z.directoryOffset:
+			problems = append(problems, Problem{
+				Kind:    ProblemGap,
+				Offset:  pos,
+				Message: fmt.Sprintf("%d bytes precede the central directory that don't belong to any file", z.directoryOffset-pos),
+			})
+		}
+	}
+	return problems
+}
+
+// verifyHeaders compares the file's local header and data descriptor with its central directory entry.
+// It returns the offset of the end of the file (including its data descriptor), or -1 if its local header can't be read.
+func (f *File) verifyHeaders() (int64, []Problem) {
+	problem := func(kind string, offset int64, format string, args ...interface{}) Problem {
+		return Problem{Kind: kind, Name: f.Name, Offset: offset, Message: fmt.Sprintf(format, args...)}
+	}
+	// Only buffer the fixed size part of the header, to keep reads of remote archives small.
+	r := io.NewSectionReader(f.zipr, f.headerOffset, 1<<63-1-f.headerOffset)
+	s := &StreamReader{src: &streamSource{r: r, br: bufio.NewReaderSize(r, fileHeaderLen), size: -1}, z: f.zip}
+	local, err := s.readLocalHeader()
+	if err == io.EOF {
+		err = ErrFormat
+	}
+	if err != nil {
+		return -1, []Problem{problem(ProblemLocalHeader, f.headerOffset, "failed to read local file header: %v", err)}
+	}
+
+	var problems []Problem
+	mismatch := func(field string, central, local interface{}) {
+		problems = append(problems, problem(ProblemLocalHeader, f.headerOffset,
+			"%s does not match the central directory (central: %v) (local: %v)", field, central, local))
+	}
+	if local.Name != f.Name {
+		mismatch("name", f.Name, local.Name)
+	}
+	if local.Method != f.Method {
+		mismatch("method", f.Method, local.Method)
+	}
+	if local.Flags != f.Flags {
+		mismatch("flags", fmt.Sprintf("%#04x", f.Flags), fmt.Sprintf("%#04x", local.Flags))
+	}
+	// With a data descriptor, the local header's sizes and checksum are usually zero.
+	if !f.hasDataDescriptor() || local.CRC32 != 0 {
+		if local.CRC32 != f.CRC32 {
+			mismatch("crc32", fmt.Sprintf("%08x", f.CRC32), fmt.Sprintf("%08x", local.CRC32))
+		}
+	}
+	if !f.hasDataDescriptor() || local.CompressedSize64 != 0 || local.UncompressedSize64 != 0 {
+		if local.CompressedSize64 != f.CompressedSize64 {
+			mismatch("compressed size", f.CompressedSize64, local.CompressedSize64)
+		}
+		if local.UncompressedSize64 != f.UncompressedSize64 {
+			mismatch("uncompressed size", f.UncompressedSize64, local.UncompressedSize64)
+		}
+	}
+
+	end := f.headerOffset + s.src.off + int64(f.CompressedSize64)
+	if !f.hasDataDescriptor() {
+		return end, problems
+	}
+	zip64 := f.zip64 || f.isZip64()
+	n := int64(dataDescriptorLen)
+	if zip64 {
+		n = dataDescriptor64Len
+	}
+	cr := &countReader{r: io.NewSectionReader(f.zipr, end, n)}
+	dd, err := readDataDescriptor(cr, zip64)
+	if err != nil {
+		problems = append(problems, problem(ProblemDataDescriptor, end, "failed to read data descriptor: %v", err))
+		return end, problems
+	}
+	if dd.compressedSize != f.CompressedSize64 || dd.uncompressedSize != f.UncompressedSize64 {
+		problems = append(problems, problem(ProblemDataDescriptor, end,
+			"sizes do not match the central directory (central: %d/%d) (descriptor: %d/%d)",
+			f.CompressedSize64, f.UncompressedSize64, dd.compressedSize, dd.uncompressedSize))
+	}
+	return end + cr.n, problems
+}
+
+// Verify reads the whole contents of the file, checking that they can be decompressed and match the checksum.
+// Encrypted files are decrypted with the Reader's password, see WithPassword.
+func (f *File) Verify() error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	_, err = io.Copy(io.Discard, rc)
+	return err
+}
+
+// countReader counts the bytes read from r.
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,147 @@
+package reader
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+)
+
+// newVerifyArchive returns an archive of a stored file without a data descriptor, "a.txt",
+// and a deflated one with a data descriptor, "b.txt", preceded by prefix.
+func newVerifyArchive(t *testing.T, prefix []byte) []byte {
+	t.Helper()
+	buf := bytes.NewBuffer(append([]byte(nil), prefix...))
+	zw := zip.NewWriter(buf)
+	zw.SetOffset(int64(len(prefix)))
+	a := []byte("contents of a")
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               "a.txt",
+		Method:             zip.Store,
+		CRC32:              crc32.ChecksumIEEE(a),
+		CompressedSize64:   uint64(len(a)),
+		UncompressedSize64: uint64(len(a)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(a)
+	w, err = zw.Create("b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("contents of b, contents of b"))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// verifyLayout holds offsets in an archive made by newVerifyArchive.
+type verifyLayout struct {
+	a, b        int64 // local headers
+	descriptor  int64 // data descriptor of b.txt
+	directory   int64 // central directory
+	directoryB  int64 // central directory header of b.txt
+	headerBSize int64 // size of the central directory header of b.txt
+}
+
+func newVerifyLayout(t *testing.T, archive []byte) verifyLayout {
+	t.Helper()
+	zr, err := NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := zr.File[0], zr.File[1]
+	l := verifyLayout{
+		a:          a.HeaderOffset(),
+		b:          b.HeaderOffset(),
+		descriptor: b.HeaderOffset() + fileHeaderLen + int64(len(b.Name)) + int64(b.CompressedSize64),
+		directory:  zr.directoryOffset,
+	}
+	l.directoryB = l.directory + directoryHeaderLen + int64(len(a.Name))
+	l.headerBSize = directoryHeaderLen + int64(len(b.Name))
+	return l
+}
+
+func TestVerifyHeaders(t *testing.T) {
+	archive := newVerifyArchive(t, nil)
+	l := newVerifyLayout(t, archive)
+	tests := []struct {
+		name    string
+		archive func() []byte
+		want    []Problem // without messages
+	}{
+		{name: "intact", archive: func() []byte { return archive }},
+		{
+			name: "local header name",
+			archive: func() []byte {
+				b := append([]byte(nil), archive...)
+				b[l.a+fileHeaderLen] = 'x'
+				return b
+			},
+			want: []Problem{{Kind: ProblemLocalHeader, Name: "a.txt", Offset: l.a}},
+		},
+		{
+			name: "local header crc32",
+			archive: func() []byte {
+				b := append([]byte(nil), archive...)
+				b[l.a+14] ^= 0xff
+				return b
+			},
+			want: []Problem{{Kind: ProblemLocalHeader, Name: "a.txt", Offset: l.a}},
+		},
+		{
+			name: "data descriptor",
+			archive: func() []byte {
+				b := append([]byte(nil), archive...)
+				// The compressed size, after the signature and CRC-32.
+				binary.LittleEndian.PutUint32(b[l.descriptor+8:], 1)
+				return b
+			},
+			want: []Problem{{Kind: ProblemDataDescriptor, Name: "b.txt", Offset: l.descriptor}},
+		},
+		{
+			// The central directory header of b.txt is replaced by that of a.txt, so that
+			// a.txt is listed twice, and b.txt's local header and data don't belong to any file.
+			name: "overlap",
+			archive: func() []byte {
+				b := append([]byte(nil), archive...)
+				copy(b[l.directoryB:l.directoryB+l.headerBSize], b[l.directory:])
+				return b
+			},
+			want: []Problem{
+				{Kind: ProblemOverlap, Name: "a.txt", Offset: l.a},
+				{Kind: ProblemGap, Offset: l.b},
+			},
+		},
+		{
+			name:    "gap",
+			archive: func() []byte { return newVerifyArchive(t, []byte("junk")) },
+			want:    []Problem{{Kind: ProblemGap, Name: "a.txt", Offset: 0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := tt.archive()
+			zr, err := NewReader(bytes.NewReader(b), int64(len(b)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			problems := zr.VerifyHeaders()
+			if len(problems) != len(tt.want) {
+				t.Fatalf("VerifyHeaders() = %+v, want %+v", problems, tt.want)
+			}
+			for i, p := range problems {
+				if p.Message == "" {
+					t.Errorf("problem %d has no message", i)
+				}
+				p.Message = ""
+				if p != tt.want[i] {
+					t.Errorf("problem %d = %+v, want %+v", i, p, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -1,11 +1,15 @@
 package zipspy
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"regexp"
+	"strings"
 
 	"github.com/alec-rabold/zipspy/pkg/reader"
+	"github.com/bmatcuk/doublestar/v4"
 )
 
 // Reader includes the necessary methods for a zip file.
@@ -14,22 +18,207 @@ type Reader interface {
 	Size() (int64, error)
 }
 
+// Versioner is implemented by readers that can identify the current version of
+// their archive (e.g. an ETag or modification time), which is used to validate cached directories.
+type Versioner interface {
+	Version() (string, error)
+}
+
+// DirectoryCache persists parsed central directories between clients.
+type DirectoryCache interface {
+	// Load returns the directory stored for the location, if it was stored for the same version.
+	Load(location, version string) ([]byte, bool)
+	// Store saves the directory for the location at the given version.
+	Store(location, version string, directory []byte) error
+}
+
+// IndexCache persists file indexes (see reader.Index) between clients.
+// A DirectoryCache passed to WithDirectoryCache is also used for indexes if it implements IndexCache.
+type IndexCache interface {
+	// LoadIndex returns the index stored for the named file in the archive at location,
+	// if it was stored for the same version.
+	LoadIndex(location, version, name string) ([]byte, bool)
+	// StoreIndex saves the index of the named file in the archive at location at the given version.
+	StoreIndex(location, version, name string, index []byte) error
+}
+
 // Client is a zipspy client.
 type Client struct {
-	r *reader.Reader
+	src     Reader // the archive, closed by Close if it implements io.Closer
+	r       *reader.Reader
+	opts    clientOptions
+	version string // version of the archive, empty if caching is disabled
+	scanned bool   // whether the files were read from local file headers
+}
+
+type clientOptions struct {
+	cache            DirectoryCache
+	location         string
+	readerOptions    []reader.Option
+	scanLocalHeaders bool
+}
+
+// Option configures a Client.
+type Option func(*clientOptions)
+
+// WithDirectoryCache loads the central directory of the archive at location from the cache when possible,
+// and stores it after reading it otherwise. It only takes effect for readers implementing Versioner.
+func WithDirectoryCache(cache DirectoryCache, location string) Option {
+	return func(o *clientOptions) {
+		o.cache = cache
+		o.location = location
+	}
+}
+
+// WithPassword sets the password used to decrypt encrypted files.
+func WithPassword(password string) Option {
+	return func(o *clientOptions) {
+		o.readerOptions = append(o.readerOptions, reader.WithPassword(password))
+	}
+}
+
+// WithLimits bounds the resources used to read the archive, see reader.Limits.
+// Reads that would exceed a limit fail with a *reader.LimitError.
+func WithLimits(limits reader.Limits) Option {
+	return func(o *clientOptions) {
+		o.readerOptions = append(o.readerOptions, reader.WithLimits(limits))
+	}
+}
+
+// WithScanLocalHeaders reads the list of files from the local file headers at the start
+// of each file rather than from the central directory (see reader.ScanLocalHeaders).
+// Without this option, local file headers are only scanned if the central directory can't be found.
+func WithScanLocalHeaders() Option {
+	return func(o *clientOptions) {
+		o.scanLocalHeaders = true
+	}
 }
 
 // NewClient creates a new top-level zipspy client.
-func NewClient(r Reader) (*Client, error) {
+func NewClient(r Reader, opts ...Option) (*Client, error) {
+	var o clientOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.scanLocalHeaders {
+		return newScanningClient(r, o)
+	}
+	version := o.version(r)
+	if version != "" {
+		if directory, ok := o.cache.Load(o.location, reader.DirectoryFormat+":"+version); ok {
+			zr, err := reader.NewReaderFromDirectory(r, directory, o.readerOptions...)
+			if err == nil {
+				return &Client{src: r, r: zr, opts: o, version: version}, nil
+			}
+			// Reading the directory from the archive would exceed the limits too.
+			if errors.Is(err, reader.ErrLimit) {
+				return nil, fmt.Errorf("failed to create zip reader: %w", err)
+			}
+		}
+	}
+
 	size, err := r.Size()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get size: %w", err)
 	}
-	zr, err := reader.NewReader(r, size)
+	zr, err := reader.NewReader(r, size, o.readerOptions...)
+	if errors.Is(err, reader.ErrNoDirectoryEnd) {
+		// The archive is truncated or still being written. Other format errors (e.g. a corrupt directory)
+		// are returned rather than hidden behind a listing that may be incomplete.
+		if c, scanErr := newScanningClient(r, o); scanErr == nil {
+			return c, nil
+		}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create zip reader: %w", err)
 	}
-	return &Client{r: zr}, nil
+	if version != "" {
+		// Caching is best effort, a failure only means the directory is read again next time.
+		if directory, err := zr.MarshalDirectory(); err == nil {
+			_ = o.cache.Store(o.location, reader.DirectoryFormat+":"+version, directory)
+		}
+	}
+	return &Client{src: r, r: zr, opts: o, version: version}, nil
+}
+
+// newScanningClient creates a client from the local file headers of the archive.
+// Directories read this way are not cached, since they may be incomplete.
+func newScanningClient(r Reader, o clientOptions) (*Client, error) {
+	size, err := r.Size()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get size: %w", err)
+	}
+	zr, err := reader.ScanLocalHeaders(r, size, o.readerOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan local file headers: %w", err)
+	}
+	return &Client{src: r, r: zr, opts: o, scanned: true}, nil
+}
+
+// Close closes the reader of the archive if it implements io.Closer (e.g. an SFTP connection).
+// Files of the archive can't be read once the client is closed.
+func (c *Client) Close() error {
+	if closer, ok := c.src.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// ScannedLocalHeaders reports whether the files of the archive were read from
+// their local file headers rather than from the central directory.
+func (c *Client) ScannedLocalHeaders() bool {
+	return c.scanned
+}
+
+// version returns the version of the archive used to validate cached data,
+// or an empty string if the cache can't be used.
+func (o *clientOptions) version(r Reader) string {
+	if o.cache == nil {
+		return ""
+	}
+	v, ok := r.(Versioner)
+	if !ok {
+		return ""
+	}
+	version, err := v.Version()
+	if err != nil || version == "" {
+		return ""
+	}
+	return version
+}
+
+// Index returns an index of the file for random access (see reader.File.BuildIndex),
+// with checkpoints every span bytes. It is loaded from the cache when an index with the same span is stored,
+// otherwise it is built by decompressing the whole file and then stored in the cache.
+func (c *Client) Index(f *reader.File, span int64) (*reader.Index, error) {
+	if span <= 0 {
+		span = reader.DefaultIndexSpan
+	}
+	cache, ok := c.opts.cache.(IndexCache)
+	if !ok || c.version == "" {
+		return f.BuildIndex(span)
+	}
+	version := reader.IndexFormat + ":" + c.version
+	if b, ok := cache.LoadIndex(c.opts.location, version, f.Name); ok {
+		// An index built with a different span is replaced, so that the span asked for is honored.
+		if idx, err := reader.UnmarshalIndex(b); err == nil && idx.Span == span {
+			return idx, nil
+		}
+	}
+	idx, err := f.BuildIndex(span)
+	if err != nil {
+		return nil, err
+	}
+	// As with directories, caching is best effort.
+	if b, err := idx.Marshal(); err == nil {
+		_ = cache.StoreIndex(c.opts.location, version, f.Name, b)
+	}
+	return idx, nil
+}
+
+// FS returns the archive as an fs.FS, with directories derived from file names.
+func (c *Client) FS() fs.FS {
+	return c.r
 }
 
 // AllFiles returns a list of all files in the archive.
@@ -60,3 +249,42 @@ func (c *Client) SearchFiles(re regexp.Regexp) []*reader.File {
 	}
 	return matches
 }
+
+// GlobFiles returns all files matching any of the include patterns and none of the exclude patterns.
+// See FilterFiles for the pattern syntax.
+func (c *Client) GlobFiles(include, exclude []string) ([]*reader.File, error) {
+	return FilterFiles(c.r.File, include, exclude)
+}
+
+// FilterFiles returns the files matching any of the include patterns (or all files if there are none)
+// and none of the exclude patterns. Patterns use doublestar semantics, where "**" matches
+// any number of directories (e.g. "logs/**/*.log"). Directory names are matched without their trailing slash.
+func FilterFiles(files []*reader.File, include, exclude []string) ([]*reader.File, error) {
+	for _, pattern := range append(append([]string{}, include...), exclude...) {
+		if !doublestar.ValidatePattern(pattern) {
+			return nil, fmt.Errorf("invalid glob pattern: %q", pattern)
+		}
+	}
+	var matches []*reader.File
+	for _, file := range files {
+		name := strings.TrimSuffix(file.Name, "/")
+		if len(include) > 0 && !matchAny(include, name) {
+			continue
+		}
+		if matchAny(exclude, name) {
+			continue
+		}
+		matches = append(matches, file)
+	}
+	return matches, nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		// Patterns are validated up front, so the error can be ignored.
+		if ok, _ := doublestar.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+package zipspy
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/alec-rabold/zipspy/pkg/reader"
+)
+
+// memReader is an archive in memory with a fixed version.
+type memReader struct {
+	*bytes.Reader
+}
+
+func (r memReader) Size() (int64, error)     { return r.Reader.Size(), nil }
+func (r memReader) Version() (string, error) { return "v1", nil }
+
+// memCache implements DirectoryCache and IndexCache in memory.
+type memCache struct {
+	entries map[string][]byte
+	stores  int
+}
+
+func (c *memCache) Load(location, version string) ([]byte, bool) {
+	b, ok := c.entries[location+"|"+version]
+	return b, ok
+}
+
+func (c *memCache) Store(location, version string, directory []byte) error {
+	c.entries[location+"|"+version] = directory
+	return nil
+}
+
+func (c *memCache) LoadIndex(location, version, name string) ([]byte, bool) {
+	b, ok := c.entries[location+"|"+version+"|"+name]
+	return b, ok
+}
+
+func (c *memCache) StoreIndex(location, version, name string, index []byte) error {
+	c.entries[location+"|"+version+"|"+name] = index
+	c.stores++
+	return nil
+}
+
+// newArchive returns an archive of a single deflated file of several MiB.
+func newArchive(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100000; i++ {
+		w.Write([]byte(strings.Repeat("zipspy ", i%13) + "\n"))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestIndexCacheSpan(t *testing.T) {
+	cache := &memCache{entries: map[string][]byte{}}
+	c, err := NewClient(memReader{bytes.NewReader(newArchive(t))}, WithDirectoryCache(cache, "archive.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := c.AllFiles()[0]
+
+	tests := []struct {
+		span   int64
+		want   int64
+		stores int // indexes stored in the cache so far
+	}{
+		{span: 1 << 20, want: 1 << 20, stores: 1},
+		{span: 1 << 20, want: 1 << 20, stores: 1}, // loaded from the cache
+		{span: 2 << 20, want: 2 << 20, stores: 2}, // rebuilt for the new span
+		{span: 0, want: reader.DefaultIndexSpan, stores: 3},
+		{span: reader.DefaultIndexSpan, want: reader.DefaultIndexSpan, stores: 3},
+	}
+	for _, tt := range tests {
+		idx, err := c.Index(f, tt.span)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if idx.Span != tt.want {
+			t.Errorf("Index(%d): Span = %d, want %d", tt.span, idx.Span, tt.want)
+		}
+		if cache.stores != tt.stores {
+			t.Errorf("Index(%d): %d indexes stored, want %d", tt.span, cache.stores, tt.stores)
+		}
+	}
+}
+
+func TestFallbackToLocalHeaders(t *testing.T) {
+	archive := newArchive(t)
+	directory := bytes.LastIndex(archive, []byte("PK\x01\x02"))
+
+	// Without the central directory, the files are read from their local headers.
+	truncated := archive[:directory]
+	c, err := NewClient(memReader{bytes.NewReader(truncated)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.ScannedLocalHeaders() {
+		t.Error("ScannedLocalHeaders() = false for a truncated archive")
+	}
+	if files := c.AllFiles(); len(files) != 1 || files[0].Name != "file.txt" {
+		t.Errorf("AllFiles() = %v, want file.txt", files)
+	}
+
+	// A corrupt central directory is reported.
+	corrupt := append([]byte{}, archive...)
+	corrupt[directory] = 'X'
+	if _, err := NewClient(memReader{bytes.NewReader(corrupt)}); !errors.Is(err, reader.ErrFormat) {
+		t.Errorf("NewClient() error = %v, want %v", err, reader.ErrFormat)
+	}
+}
+
+func TestDirectoryCacheLimits(t *testing.T) {
+	cache := &memCache{entries: map[string][]byte{}}
+	archive := newArchive(t)
+	if _, err := NewClient(memReader{bytes.NewReader(archive)}, WithDirectoryCache(cache, "archive.zip")); err != nil {
+		t.Fatal(err)
+	}
+	if len(cache.entries) != 1 {
+		t.Fatalf("%d directories cached, want 1", len(cache.entries))
+	}
+
+	// The cached directory is subject to the limits, which aren't lifted by reading the archive's own.
+	_, err := NewClient(memReader{bytes.NewReader(archive)}, WithDirectoryCache(cache, "archive.zip"),
+		WithLimits(reader.Limits{MaxDirectorySize: 10}))
+	var limitErr *reader.LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != reader.LimitDirectorySize {
+		t.Errorf("NewClient() error = %v, want a %s LimitError", err, reader.LimitDirectorySize)
+	}
+}
+
+// newNamedArchive returns an archive of empty files with the given names, in order.
+func newNamedArchive(t *testing.T, names ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		if _, err := zw.Create(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFilterFiles(t *testing.T) {
+	archive := newNamedArchive(t, "README.md", "data/", "data/a.json", "data/b.txt", "logs/app.log", "logs/debug/", "logs/debug/trace.log")
+	c, err := NewClient(memReader{bytes.NewReader(archive)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each flag narrows the selection: files must match the regular expression (--match),
+	// one of the include patterns (--glob) and none of the exclude patterns (--exclude).
+	tests := []struct {
+		name    string
+		match   string
+		include []string
+		exclude []string
+		want    []string
+	}{
+		{name: "all", want: []string{"README.md", "data/", "data/a.json", "data/b.txt", "logs/app.log", "logs/debug/", "logs/debug/trace.log"}},
+		{name: "match", match: `\.log$`, want: []string{"logs/app.log", "logs/debug/trace.log"}},
+		{name: "glob", include: []string{"logs/**/*.log"}, want: []string{"logs/app.log", "logs/debug/trace.log"}},
+		{name: "globs", include: []string{"*.md", "data/*.json"}, want: []string{"README.md", "data/a.json"}},
+		// "*" doesn't match slashes, and directories are matched without their trailing slash.
+		{name: "single star", include: []string{"*"}, want: []string{"README.md", "data/"}},
+		{name: "directories", include: []string{"*/*"}, want: []string{"data/a.json", "data/b.txt", "logs/app.log", "logs/debug/"}},
+		{name: "exclude", exclude: []string{"logs/**"}, want: []string{"README.md", "data/", "data/a.json", "data/b.txt"}},
+		{name: "exclude wins over glob", include: []string{"logs/**"}, exclude: []string{"logs/debug/**"}, want: []string{"logs/app.log"}},
+		{name: "match and glob", match: `\.(json|md)$`, include: []string{"data/**"}, want: []string{"data/a.json"}},
+		{name: "match and exclude", match: `\.log$`, exclude: []string{"**/debug/**"}, want: []string{"logs/app.log"}},
+		{name: "match, glob and exclude", match: `^[dl]`, include: []string{"**/*.*"}, exclude: []string{"*/*.txt"}, want: []string{"data/a.json", "logs/app.log", "logs/debug/trace.log"}},
+		{name: "nothing", match: `\.json$`, include: []string{"logs/**"}},
+	}
+	for _, tt := range tests {
+		files := c.AllFiles()
+		if tt.match != "" {
+			files = c.SearchFiles(*regexp.MustCompile(tt.match))
+		}
+		files, err := FilterFiles(files, tt.include, tt.exclude)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var got []string
+		for _, f := range files {
+			got = append(got, f.Name)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: files = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := c.GlobFiles([]string{"logs/[a-"}, nil); err == nil {
+		t.Error("GlobFiles() with an invalid pattern: no error")
+	}
+}